@@ -1,30 +1,68 @@
 package api
 
 import (
-	"encoding/base64"
+	"bytes"
+	"fmt"
+	"github.com/jvdanker/mug/capture"
+	"github.com/jvdanker/mug/imgdiff"
+	"github.com/jvdanker/mug/report"
 	"github.com/jvdanker/mug/store"
-	"io/ioutil"
+	"github.com/jvdanker/mug/transfer"
 	"net/http"
-	"os"
-	"os/exec"
+	"net/url"
 )
 
 type Api interface {
 	GetUpdates() (interface{}, error)
-	List() ([]store.Url, error)
-	ScanAll(t string) (interface{}, error)
-	SubmitScanRequest(id int) error
+	List(f Filter) ([]store.Url, error)
+	ScanAll(req ScanRequest) (interface{}, error)
+	SubmitScanRequest(id int, baseUrl string) error
 	Init(id int) (interface{}, error)
+	Diff(id int) (DiffResponse, error)
 	PDiff(id int) (DiffResponse, error)
+	AlignDiff(id int) (DiffResponse, error)
 	GetReferenceScreenshot(id int) (interface{}, error)
 	GetScanScreenshot(id int) (interface{}, error)
-	AddUrl(url string) (interface{}, error)
+	GetOverlayImage(id int) (store.RawResponse, error)
+	Report(format string, baseUrl string) (store.RawResponse, error)
+	GetEvents(id int) (interface{}, error)
+	GetDomChanges(id int) (interface{}, error)
+	AddUrl(url store.Url) (interface{}, error)
+	Discover(req DiscoverRequest) (DiscoverResponse, error)
+	BulkAdd(urls []store.Url) (BulkResponse, error)
+	BulkUpdate(urls []store.Url) (BulkResponse, error)
+	BulkDelete(ids []int) (BulkResponse, error)
+	Export(format string) (store.RawResponse, error)
+	Import(format string, mode transfer.Mode, dryRun bool, data []byte) (ImportResponse, error)
 	DeleteUrl(id int) (interface{}, error)
+	ListGroups() ([]store.Group, error)
+	SaveGroup(group store.Group) (interface{}, error)
+	DeleteGroup(name string) (interface{}, error)
+	Compare(req CompareRequest) (store.Comparison, error)
+	ListComparisons() ([]store.Comparison, error)
+	GetComparison(id int) (*store.Comparison, error)
+	StartRun(req ScanRequest) (store.Run, error)
+	ListRuns(branch, commit string) ([]store.Run, error)
+	GetRun(id int) (*store.Run, error)
+	ListWebhooks() ([]store.Webhook, error)
+	SaveWebhook(h store.Webhook) (store.Webhook, error)
+	DeleteWebhook(id int) (interface{}, error)
+	TestWebhook(id int, event string) ([]store.Delivery, error)
+	ListDeliveries(id int) ([]store.Delivery, error)
+	ListCommitStatuses() ([]store.CommitStatus, error)
+	SaveCommitStatus(c store.CommitStatus) (store.CommitStatus, error)
+	DeleteCommitStatus(id int) (interface{}, error)
+	ListProjects() ([]store.Project, error)
+	SaveProject(project store.Project) (interface{}, error)
+	DeleteProject(name string) (interface{}, error)
 }
 
 type DiffResponse struct {
-	Output string `json:"output"`
-	Status bool   `json:"status"`
+	Output  string         `json:"output"`
+	Status  bool           `json:"status"`
+	Score   store.Score    `json:"score"`
+	Regions []store.Region `json:"regions"`
+	Shifts  []store.Shift  `json:"shifts"`
 }
 
 type MugApi struct {
@@ -46,46 +84,68 @@ func (a MugApi) GetUpdates() (interface{}, error) {
 	}
 }
 
-func (a MugApi) List() ([]store.Url, error) {
+func (a MugApi) List(f Filter) ([]store.Url, error) {
 	fs := store.NewFileStore()
 	err := fs.Open()
 	if err != nil {
 		return nil, err
 	}
 
-	return fs.List(), nil
-}
-
-func (a MugApi) ScanAll(t string) (interface{}, error) {
-	fs := store.NewFileStore()
-	err := fs.Open()
+	urls, err := filterUrls(fs.List(), f)
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range urls {
+		urls[i] = urls[i].Redacted()
+	}
+
+	return urls, nil
+}
+
+// ScanRequest scans the urls that match the filter. Type is "current" or
+// "reference". Branch, Commit, TriggeredBy and Labels describe the run the
+// scans are part of.
+type ScanRequest struct {
+	Type string `json:"type"`
+	Filter
+	// BaseUrl captures the urls on another deployment than the one their
+	// project points to, e.g. a preview of a pull request.
+	BaseUrl     string            `json:"baseUrl"`
+	Branch      string            `json:"branch"`
+	Commit      string            `json:"commit"`
+	TriggeredBy string            `json:"triggeredBy"`
+	Labels      map[string]string `json:"labels"`
+	// ServerUrl is the url of this server, the run links to itself with it.
+	ServerUrl string `json:"-"`
+}
+
+// ScanAll captures the current or reference screenshots of the urls that
+// match the filter, as a run.
+func (a MugApi) ScanAll(req ScanRequest) (interface{}, error) {
 	type Response struct {
 		Ids []int `json:"ids"`
+		Run int   `json:"run"`
 	}
 
-	var response Response
+	run, err := a.StartRun(req)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, item := range fs.List() {
-		switch t {
-		case "current":
-			response.Ids = append(response.Ids, item.Id)
-			a.worker.c <- WorkItem{Type: UpdateCurrent, Url: item}
-		case "reference":
-			response.Ids = append(response.Ids, item.Id)
-			a.worker.c <- WorkItem{Type: UpdateReference, Url: item}
-		default:
-			panic("Unsupported type " + t)
-		}
+	response := Response{Run: run.Id}
+	for _, r := range run.Results {
+		response.Ids = append(response.Ids, r.Id)
 	}
 
 	return response, nil
 }
 
-func (a MugApi) SubmitScanRequest(id int) error {
+func (a MugApi) SubmitScanRequest(id int, baseUrl string) error {
+	if err := validBaseUrl(baseUrl); err != nil {
+		return err
+	}
+
 	fs := store.NewFileStore()
 	err := fs.Open()
 	if err != nil {
@@ -97,7 +157,7 @@ func (a MugApi) SubmitScanRequest(id int) error {
 		return store.HandlerError{"", http.StatusNotFound}
 	}
 
-	a.worker.c <- WorkItem{Type: UpdateCurrent, Url: *item}
+	a.worker.c <- WorkItem{Type: UpdateCurrent, Url: *item, BaseUrl: baseUrl}
 
 	return nil
 }
@@ -114,12 +174,14 @@ func (a MugApi) Init(id int) (interface{}, error) {
 		return nil, err
 	}
 
-	_, data, err := CreateScreenshot(item.Url)
+	screenshot, err := CreateScreenshot(a.worker.capturer, *item, "")
 	if err != nil {
 		return nil, err
 	}
 
-	item.Reference = data
+	item.Reference = screenshot.Thumbnail
+	item.ReferenceEvents = screenshot.Events
+	item.ReferenceDom = &screenshot.Dom
 	err = fs.Update(*item)
 	if err != nil {
 		return nil, err
@@ -130,80 +192,133 @@ func (a MugApi) Init(id int) (interface{}, error) {
 	return item, nil
 }
 
-func (a MugApi) PDiff(id int) (DiffResponse, error) {
-	fs := store.NewFileStore()
-	err := fs.Open()
+func (a MugApi) Diff(id int) (DiffResponse, error) {
+	item, i1, i2, err := loadImages(id)
 	if err != nil {
 		return DiffResponse{}, err
 	}
 
-	output := ""
-	status := true
+	return diffImages(*item, i1, i2)
+}
 
-	item, err := fs.Get(id)
+func (a MugApi) PDiff(id int) (DiffResponse, error) {
+	item, i1, i2, err := loadImages(id)
 	if err != nil {
 		return DiffResponse{}, err
 	}
 
-	if item.Reference == "" || item.Current == "" {
-		return DiffResponse{}, store.HandlerError{"Missing reference or current image", http.StatusInternalServerError}
-	}
+	return metricDiff(*item, "perceptual", i1, i2)
+}
 
-	str1 := item.Reference[len("data::image/png;base64,"):]
-	b1, err := base64.StdEncoding.DecodeString(str1)
+func (a MugApi) AlignDiff(id int) (DiffResponse, error) {
+	item, i1, i2, err := loadImages(id)
 	if err != nil {
 		return DiffResponse{}, err
 	}
 
-	err = ioutil.WriteFile("i1.png", b1, 0644)
+	return alignDiff(*item, i1, i2), nil
+}
+
+func (a MugApi) GetReferenceScreenshot(id int) (interface{}, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
 	if err != nil {
-		return DiffResponse{}, err
+		return nil, err
+	}
+
+	item, err := fs.Get(id)
+	if err != nil || item.Reference == "" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	type ScreenshotResponse struct {
+		Data string `json:"data"`
 	}
 
-	str2 := item.Current[len("data::image/png;base64,"):]
-	b2, err := base64.StdEncoding.DecodeString(str2)
+	response := ScreenshotResponse{
+		Data: item.Reference,
+	}
+
+	return response, nil
+}
+
+func (a MugApi) GetScanScreenshot(id int) (interface{}, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
 	if err != nil {
-		return DiffResponse{}, err
+		return nil, err
+	}
+
+	item, err := fs.Get(id)
+	if err != nil || item.Current == "" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	type ScreenshotResponse struct {
+		Data string `json:"data"`
 	}
 
-	err = ioutil.WriteFile("i2.png", b2, 0644)
+	response := ScreenshotResponse{
+		Data: item.Current,
+	}
+
+	return response, nil
+}
+
+// GetOverlayImage returns the overlay of the last scan as an image, so that
+// reports can link to it.
+func (a MugApi) GetOverlayImage(id int) (store.RawResponse, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
 	if err != nil {
-		return DiffResponse{}, err
+		return store.RawResponse{}, err
 	}
 
-	dir, err := os.Getwd()
+	item, err := fs.Get(id)
+	if err != nil || item.Overlay == "" {
+		return store.RawResponse{}, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	contentType, data, err := dataUriBytes(item.Overlay)
 	if err != nil {
-		return DiffResponse{}, err
+		return store.RawResponse{}, err
 	}
 
-	cmd := exec.Command("docker",
-		"run",
-		"--rm",
-		"-v",
-		dir+":/images",
-		"jvdanker/pdiff",
-		"-verbose",
-		"i1.png",
-		"i2.png")
+	return store.RawResponse{ContentType: contentType, Data: data}, nil
+}
+
+// Report returns the results of the last scan as a JUnit or TAP report, or
+// as a zip with a HTML report. Overlay images are attached as urls below
+// baseUrl.
+func (a MugApi) Report(format string, baseUrl string) (store.RawResponse, error) {
+	if format == "html" {
+		return htmlReport()
+	}
 
-	temp, err := cmd.CombinedOutput()
+	write, contentType, err := report.Format(format)
 	if err != nil {
-		status = cmd.ProcessState.Success()
-		output = string(temp)
-	} else {
-		status = cmd.ProcessState.Success()
-		output = string(temp)
+		return store.RawResponse{}, store.HandlerError{err.Error(), http.StatusBadRequest}
 	}
 
-	response := DiffResponse{
-		Output: output,
-		Status: status,
+	fs := store.NewFileStore()
+	err = fs.Open()
+	if err != nil {
+		return store.RawResponse{}, err
 	}
 
-	return response, nil
+	cases := report.FromUrls(fs.List(), func(u store.Url) string {
+		return fmt.Sprintf("%s/screenshot/overlay/%d", baseUrl, u.Id)
+	})
+
+	buf := new(bytes.Buffer)
+	if err = write(buf, "mug", cases); err != nil {
+		return store.RawResponse{}, err
+	}
+
+	return store.RawResponse{ContentType: contentType, Data: buf.Bytes()}, nil
 }
 
-func (a MugApi) GetReferenceScreenshot(id int) (interface{}, error) {
+func (a MugApi) GetEvents(id int) (interface{}, error) {
 	fs := store.NewFileStore()
 	err := fs.Open()
 	if err != nil {
@@ -211,22 +326,26 @@ func (a MugApi) GetReferenceScreenshot(id int) (interface{}, error) {
 	}
 
 	item, err := fs.Get(id)
-	if err != nil || item.Reference == "" {
+	if err != nil {
 		return nil, store.HandlerError{"", http.StatusNotFound}
 	}
 
-	type ScreenshotResponse struct {
-		Data string `json:"data"`
+	type EventsResponse struct {
+		Reference []store.PageEvent `json:"reference"`
+		Current   []store.PageEvent `json:"current"`
+		New       []store.PageEvent `json:"new"`
 	}
 
-	response := ScreenshotResponse{
-		Data: item.Reference,
+	response := EventsResponse{
+		Reference: item.ReferenceEvents,
+		Current:   item.CurrentEvents,
+		New:       newErrors(item.ReferenceEvents, item.CurrentEvents),
 	}
 
 	return response, nil
 }
 
-func (a MugApi) GetScanScreenshot(id int) (interface{}, error) {
+func (a MugApi) GetDomChanges(id int) (interface{}, error) {
 	fs := store.NewFileStore()
 	err := fs.Open()
 	if err != nil {
@@ -234,22 +353,92 @@ func (a MugApi) GetScanScreenshot(id int) (interface{}, error) {
 	}
 
 	item, err := fs.Get(id)
-	if err != nil || item.Current == "" {
+	if err != nil {
 		return nil, store.HandlerError{"", http.StatusNotFound}
 	}
 
-	type ScreenshotResponse struct {
-		Data string `json:"data"`
+	type DomChangesResponse struct {
+		Changes []store.DomChange `json:"changes"`
+		Overlay string            `json:"overlay"`
 	}
 
-	response := ScreenshotResponse{
-		Data: item.Current,
+	response := DomChangesResponse{
+		Changes: item.DomChanges,
+		Overlay: item.Overlay,
 	}
 
 	return response, nil
 }
 
-func (a MugApi) AddUrl(url string) (interface{}, error) {
+func (a MugApi) AddUrl(u store.Url) (interface{}, error) {
+	if err := validateUrl(u); err != nil {
+		return nil, err
+	}
+
+	ids, err := a.addUrls([]store.Url{u})
+	if err != nil {
+		return nil, err
+	}
+
+	type Response struct {
+		Id int `json:"id"`
+	}
+
+	return Response{Id: ids[0]}, nil
+}
+
+func validateUrl(u store.Url) error {
+	if u.Url == "" {
+		return store.HandlerError{"Missing url", http.StatusBadRequest}
+	}
+
+	// A path needs a base url from its group or project.
+	s, err := resolve(u)
+	if err != nil {
+		return err
+	}
+	if p, err := url.Parse(s.Url); err != nil || !p.IsAbs() {
+		return store.HandlerError{u.Url + " is a path, but its group and project have no base url", http.StatusBadRequest}
+	}
+
+	return validateSettings(u)
+}
+
+// validateSettings checks the settings of a url, but not the url itself,
+// e.g. of the template for discovered urls.
+func validateSettings(u store.Url) error {
+	if _, err := resolve(u); err != nil {
+		return err
+	}
+
+	if err := capture.ValidFormat(u.Format, u.Quality); err != nil {
+		return store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	if u.Metric != "" {
+		_, err := imgdiff.NewMetric(u.Metric, metricOptions(u))
+		if err != nil {
+			return store.HandlerError{err.Error(), http.StatusBadRequest}
+		}
+	}
+
+	return nil
+}
+
+func validBaseUrl(baseUrl string) error {
+	if baseUrl == "" {
+		return nil
+	}
+
+	if err := store.ValidBaseUrl(baseUrl); err != nil {
+		return store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+// addUrls stores the urls with new ids and queues their first captures.
+func (a MugApi) addUrls(urls []store.Url) ([]int, error) {
 	fs := store.NewFileStore()
 	err := fs.Open()
 	if err != nil {
@@ -263,25 +452,26 @@ func (a MugApi) AddUrl(url string) (interface{}, error) {
 		}
 	}
 
-	u := store.Url{
-		Url: url,
-		Id:  max + 1,
-	}
+	var ids []int
+	var work []WorkItem
+	for _, u := range urls {
+		max++
+		u.Id = max
 
-	err = fs.Add(u)
-	if err != nil {
-		return nil, err
+		err = fs.Add(u)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, u.Id)
+		work = append(work, WorkItem{Type: NewUrl, Url: u})
 	}
 
 	fs.Close()
 
-	a.worker.c <- WorkItem{Type: NewUrl, Url: u}
-
-	type Response struct {
-		Id int `json:"id"`
-	}
+	a.worker.submit(work...)
 
-	return Response{Id: max + 1}, nil
+	return ids, nil
 }
 
 func (a MugApi) DeleteUrl(id int) (interface{}, error) {
@@ -300,3 +490,134 @@ func (a MugApi) DeleteUrl(id int) (interface{}, error) {
 
 	return nil, nil
 }
+
+func (a MugApi) ListGroups() ([]store.Group, error) {
+	gs := store.NewGroupStore()
+	err := gs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []store.Group{}
+	for _, g := range gs.List() {
+		groups = append(groups, g.Redacted())
+	}
+
+	return groups, nil
+}
+
+func (a MugApi) SaveGroup(group store.Group) (interface{}, error) {
+	if group.Name == "" {
+		return nil, store.HandlerError{"Missing group name", http.StatusBadRequest}
+	}
+
+	if group.Project != "" {
+		ps := store.NewProjectStore()
+		err := ps.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = ps.Get(group.Project); err != nil {
+			return nil, store.HandlerError{"Unknown project " + group.Project, http.StatusBadRequest}
+		}
+	}
+
+	gs := store.NewGroupStore()
+	err := gs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	// Credentials come back redacted, empty secrets keep the stored ones.
+	var stored *store.Credentials
+	if g, err := gs.Get(group.Name); err == nil {
+		stored = g.Credentials
+	}
+	group.Credentials = group.Credentials.Keep(stored)
+
+	err = gs.Save(group)
+	if err != nil {
+		return nil, err
+	}
+
+	gs.Close()
+
+	return group.Redacted(), nil
+}
+
+func (a MugApi) DeleteGroup(name string) (interface{}, error) {
+	gs := store.NewGroupStore()
+	err := gs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gs.Delete(name)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	gs.Close()
+
+	return nil, nil
+}
+
+func (a MugApi) ListProjects() ([]store.Project, error) {
+	ps := store.NewProjectStore()
+	err := ps.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []store.Project{}
+	for _, p := range ps.List() {
+		projects = append(projects, p.Redacted())
+	}
+
+	return projects, nil
+}
+
+func (a MugApi) SaveProject(project store.Project) (interface{}, error) {
+	if project.Name == "" {
+		return nil, store.HandlerError{"Missing project name", http.StatusBadRequest}
+	}
+
+	ps := store.NewProjectStore()
+	err := ps.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored *store.Credentials
+	if p, err := ps.Get(project.Name); err == nil {
+		stored = p.Credentials
+	}
+	project.Credentials = project.Credentials.Keep(stored)
+
+	err = ps.Save(project)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.Close()
+
+	return project.Redacted(), nil
+}
+
+func (a MugApi) DeleteProject(name string) (interface{}, error) {
+	ps := store.NewProjectStore()
+	err := ps.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ps.Delete(name)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	ps.Close()
+
+	return nil, nil
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/transfer"
+	"net/http"
+)
+
+type BulkResponse struct {
+	Ids []int `json:"ids"`
+}
+
+// BulkAdd adds several urls at once. Nothing is added when one of them is
+// invalid.
+func (a MugApi) BulkAdd(urls []store.Url) (BulkResponse, error) {
+	for _, u := range urls {
+		if err := validateUrl(u); err != nil {
+			return BulkResponse{}, badUrl(u, err)
+		}
+	}
+
+	ids, err := a.addUrls(urls)
+	return BulkResponse{Ids: ids}, err
+}
+
+// BulkUpdate changes the settings of several urls, found by their id.
+// Screenshots and results are kept. Nothing is updated when one of the urls
+// is invalid or doesn't exist.
+func (a MugApi) BulkUpdate(urls []store.Url) (BulkResponse, error) {
+	var response BulkResponse
+
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return response, err
+	}
+
+	for _, u := range urls {
+		if err = validateUrl(u); err != nil {
+			return response, badUrl(u, err)
+		}
+		if _, err = fs.Get(u.Id); err != nil {
+			return response, store.HandlerError{fmt.Sprintf("url %d not found", u.Id), http.StatusNotFound}
+		}
+	}
+
+	for _, u := range urls {
+		item, _ := fs.Get(u.Id)
+		u.Credentials = u.Credentials.Keep(item.Credentials)
+		transfer.FromUrl(u).Apply(item)
+		response.Ids = append(response.Ids, u.Id)
+	}
+
+	fs.Close()
+
+	return response, nil
+}
+
+// BulkDelete deletes several urls. Nothing is deleted when one of them
+// doesn't exist.
+func (a MugApi) BulkDelete(ids []int) (BulkResponse, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return BulkResponse{}, err
+	}
+
+	for _, id := range ids {
+		if _, err = fs.Get(id); err != nil {
+			return BulkResponse{}, store.HandlerError{fmt.Sprintf("url %d not found", id), http.StatusNotFound}
+		}
+	}
+
+	for _, id := range ids {
+		if err = fs.Delete(id); err != nil {
+			return BulkResponse{}, err
+		}
+	}
+
+	fs.Close()
+
+	return BulkResponse{Ids: ids}, nil
+}
+
+func badUrl(u store.Url, err error) error {
+	if he, ok := err.(store.HandlerError); ok {
+		he.Message = u.Url + ": " + he.Message
+		return he
+	}
+	return err
+}
+
+// Export returns the settings of all urls as json, yaml or csv.
+func (a MugApi) Export(format string) (store.RawResponse, error) {
+	contentType := transfer.ContentType(format)
+	if contentType == "" {
+		return store.RawResponse{}, store.HandlerError{fmt.Sprintf("unknown format %q, use json, yaml or csv", format), http.StatusBadRequest}
+	}
+
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return store.RawResponse{}, err
+	}
+
+	var settings []transfer.Settings
+	for _, u := range fs.List() {
+		settings = append(settings, transfer.FromUrl(u.Redacted()))
+	}
+
+	buf := new(bytes.Buffer)
+	if err = transfer.Encode(buf, format, settings); err != nil {
+		return store.RawResponse{}, err
+	}
+
+	return store.RawResponse{
+		ContentType: contentType,
+		Filename:    "urls." + format,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+type ImportResponse struct {
+	DryRun    bool              `json:"dryRun"`
+	Added     int               `json:"added"`
+	Updated   int               `json:"updated"`
+	Deleted   int               `json:"deleted"`
+	Unchanged int               `json:"unchanged"`
+	Changes   []transfer.Change `json:"changes"`
+	// Ids of the added urls.
+	Ids []int `json:"ids"`
+}
+
+// Import adds and updates urls from an export. In replace mode the urls that
+// are not in it are deleted. With dryRun only the changes are returned.
+func (a MugApi) Import(format string, mode transfer.Mode, dryRun bool, data []byte) (ImportResponse, error) {
+	response := ImportResponse{DryRun: dryRun}
+
+	settings, err := transfer.Decode(bytes.NewReader(data), format)
+	if err != nil {
+		return response, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	for _, s := range settings {
+		if err = validateUrl(s.ToUrl()); err != nil {
+			return response, badUrl(s.ToUrl(), err)
+		}
+	}
+
+	fs := store.NewFileStore()
+	err = fs.Open()
+	if err != nil {
+		return response, err
+	}
+
+	response.Changes, err = transfer.Plan(fs.List(), settings, mode)
+	if err != nil {
+		return response, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	var added []store.Url
+	for _, c := range response.Changes {
+		switch c.Action {
+		case transfer.Add:
+			response.Added++
+			added = append(added, c.Settings.ToUrl())
+		case transfer.Update:
+			response.Updated++
+			if !dryRun {
+				item, _ := fs.Get(c.Id)
+				c.Settings.Apply(item)
+			}
+		case transfer.Delete:
+			response.Deleted++
+			if !dryRun {
+				fs.Delete(c.Id)
+			}
+		case transfer.Unchanged:
+			response.Unchanged++
+		}
+	}
+
+	if dryRun {
+		return response, nil
+	}
+
+	fs.Close()
+
+	if len(added) > 0 {
+		response.Ids, err = a.addUrls(added)
+	}
+
+	return response, err
+}
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// CompareRequest compares the same paths on two deployments. Without paths
+// the stored urls that match the filter are compared, with their settings.
+type CompareRequest struct {
+	Base     string          `json:"base"`
+	Target   string          `json:"target"`
+	Mappings []store.Mapping `json:"mappings"`
+	Paths    []string        `json:"paths"`
+	Filter
+}
+
+// comparisonLock keeps comparisons that run at the same time from
+// overwriting each other's progress.
+var comparisonLock sync.Mutex
+
+type mapping struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+// Compare starts a comparison and returns it right away. The worker runs it
+// in the background, GetComparison shows its progress and results. The stored urls
+// and their screenshots are not changed.
+func (a MugApi) Compare(req CompareRequest) (store.Comparison, error) {
+	if req.Base == "" || req.Target == "" {
+		return store.Comparison{}, store.HandlerError{"Missing base or target", http.StatusBadRequest}
+	}
+	for _, u := range []string{req.Base, req.Target} {
+		if err := validBaseUrl(u); err != nil {
+			return store.Comparison{}, err
+		}
+	}
+
+	if _, err := compileMappings(req.Mappings); err != nil {
+		return store.Comparison{}, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	var items []store.Url
+	for _, p := range req.Paths {
+		items = append(items, store.Url{Url: p})
+	}
+
+	if len(req.Paths) == 0 || !req.Filter.empty() {
+		fs := store.NewFileStore()
+		err := fs.Open()
+		if err != nil {
+			return store.Comparison{}, err
+		}
+
+		urls, err := filterUrls(fs.List(), req.Filter)
+		if err != nil {
+			return store.Comparison{}, err
+		}
+		items = append(items, urls...)
+	}
+
+	if len(items) == 0 {
+		return store.Comparison{}, store.HandlerError{"Nothing to compare", http.StatusBadRequest}
+	}
+
+	comparisonLock.Lock()
+	defer comparisonLock.Unlock()
+
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		return store.Comparison{}, err
+	}
+
+	max := 0
+	for _, c := range cs.List() {
+		if c.Id > max {
+			max = c.Id
+		}
+	}
+
+	c := store.Comparison{
+		Id:       max + 1,
+		Base:     req.Base,
+		Target:   req.Target,
+		Mappings: req.Mappings,
+		Paths:    req.Paths,
+		Project:  req.Project,
+		Group:    req.Group,
+		Tag:      req.Tag,
+		State:    store.ComparisonRunning,
+		Total:    len(items),
+		Created:  time.Now(),
+	}
+
+	err = cs.Add(c)
+	if err != nil {
+		return store.Comparison{}, err
+	}
+
+	cs.Close()
+
+	var work []WorkItem
+	for _, item := range items {
+		work = append(work, WorkItem{Type: CompareUrl, Url: item, Comparison: c.Id})
+	}
+	a.worker.submit(work...)
+
+	return c, nil
+}
+
+func compileMappings(ms []store.Mapping) ([]mapping, error) {
+	var mappings []mapping
+	for _, m := range ms {
+		re, err := regexp.Compile(m.Match)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping{re, m.Replace})
+	}
+
+	return mappings, nil
+}
+
+// compare diffs one url of a comparison and records the result. The worker
+// does it, so that the captures of comparisons and scans take turns.
+func (w Worker) compare(work WorkItem) {
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	c, err := cs.Get(work.Comparison)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The mappings were checked when the comparison started.
+	mappings, _ := compileMappings(c.Mappings)
+	r := NewApi(w).compareUrl(work.Url, c.Base, c.Target, mappings)
+
+	if err = finishComparisonItem(c.Id, r); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// finishComparisonItem adds a result to its comparison, which is finished
+// once all its urls are.
+func finishComparisonItem(id int, r store.ComparisonResult) error {
+	comparisonLock.Lock()
+	defer comparisonLock.Unlock()
+
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		return err
+	}
+
+	c, err := cs.Get(id)
+	if err != nil {
+		return err
+	}
+	if c.State != store.ComparisonRunning {
+		return nil
+	}
+
+	c.Results = append(c.Results, r)
+	c.Done++
+	if r.Url.Status > c.Status {
+		c.Status = r.Url.Status
+	}
+
+	if c.Done == c.Total {
+		now := time.Now()
+		c.State = store.ComparisonFinished
+		c.Finished = &now
+	}
+
+	err = cs.Update(*c)
+	if err != nil {
+		return err
+	}
+
+	cs.Close()
+
+	return nil
+}
+
+// FailInterruptedComparisons fails the comparisons that were still running
+// when the server stopped. Their work was only queued in memory, they would
+// never finish.
+func FailInterruptedComparisons() error {
+	comparisonLock.Lock()
+	defer comparisonLock.Unlock()
+
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, c := range cs.List() {
+		if c.State != store.ComparisonRunning {
+			continue
+		}
+
+		c.State = store.ComparisonFailed
+		c.Status = store.FAIL
+		c.Error = "interrupted by a restart of the server"
+		c.Finished = &now
+		if err = cs.Update(c); err != nil {
+			return err
+		}
+	}
+
+	cs.Close()
+
+	return nil
+}
+
+// compareUrl captures the path of item on base and target and diffs the
+// screenshots with each other. Errors fail the result.
+func (a MugApi) compareUrl(item store.Url, base, target string, mappings []mapping) store.ComparisonResult {
+	path := urlPath(item.Url)
+	targetPath := mapPath(mappings, path)
+
+	r := store.ComparisonResult{
+		Base:   resolveUrl(base, path),
+		Target: resolveUrl(target, targetPath),
+	}
+
+	err := a.captureBoth(&item, base, target, targetPath)
+	if err == nil {
+		var resp DiffResponse
+		resp, err = a.diffBoth(item)
+		if err == nil {
+			err = applyDiff(&item, resp)
+		}
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+		item.Status = store.FAIL
+	}
+	r.Url = item
+
+	return r
+}
+
+// captureBoth captures item on base as the reference and targetPath on
+// target as the current screenshot. The credentials of item are only sent
+// to its own host.
+func (a MugApi) captureBoth(item *store.Url, base, target, targetPath string) error {
+	screenshot, err := CreateScreenshot(a.worker.capturer, *item, base)
+	if err != nil {
+		return fmt.Errorf("%v: %v", resolveUrl(base, urlPath(item.Url)), err)
+	}
+	item.Reference = screenshot.Thumbnail
+	item.ReferenceEvents = screenshot.Events
+	item.ReferenceDom = &screenshot.Dom
+
+	cur := *item
+	cur.Url = targetPath
+	screenshot, err = CreateScreenshot(a.worker.capturer, cur, target)
+	if err != nil {
+		return fmt.Errorf("%v: %v", resolveUrl(target, targetPath), err)
+	}
+	item.Current = screenshot.Thumbnail
+	item.CurrentEvents = screenshot.Events
+	item.CurrentDom = &screenshot.Dom
+
+	return nil
+}
+
+func (a MugApi) diffBoth(item store.Url) (DiffResponse, error) {
+	i1, i2, err := decodeImages(item)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+
+	return diffImages(item, i1, i2)
+}
+
+// urlPath returns the path, query included, of a stored url. Urls that are
+// stored as a path are returned as they are.
+func urlPath(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || !u.IsAbs() {
+		return s
+	}
+
+	return u.RequestURI()
+}
+
+// mapPath applies the first mapping that matches.
+func mapPath(mappings []mapping, path string) string {
+	for _, m := range mappings {
+		if m.match.MatchString(path) {
+			return m.match.ReplaceAllString(path, m.replace)
+		}
+	}
+
+	return path
+}
+
+// ListComparisons returns the comparisons without the screenshots of their
+// results, GetComparison has them.
+func (a MugApi) ListComparisons() ([]store.Comparison, error) {
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	comparisons := []store.Comparison{}
+	for _, c := range cs.List() {
+		c = redactComparison(c)
+		for i := range c.Results {
+			u := &c.Results[i].Url
+			u.Reference, u.Current, u.Overlay = "", "", ""
+		}
+		comparisons = append(comparisons, c)
+	}
+
+	return comparisons, nil
+}
+
+func (a MugApi) GetComparison(id int) (*store.Comparison, error) {
+	cs := store.NewComparisonStore()
+	err := cs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cs.Get(id)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	r := redactComparison(*c)
+	return &r, nil
+}
+
+// redactComparison returns c with the secrets of the credentials of its
+// results left out, in a copy of its results.
+func redactComparison(c store.Comparison) store.Comparison {
+	results := make([]store.ComparisonResult, len(c.Results))
+	for i, r := range c.Results {
+		r.Url = r.Url.Redacted()
+		results[i] = r
+	}
+	c.Results = results
+
+	return c
+}
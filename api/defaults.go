@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/jvdanker/mug/capture"
+	"github.com/jvdanker/mug/store"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/url"
+	"strings"
+)
+
+// settings are the settings a url is captured and compared with: its own,
+// completed with the defaults of its group and project.
+type settings struct {
+	Project     string
+	Url         string
+	Viewport    store.Viewport
+	Masks       []store.Mask
+	Thresholds  store.Thresholds
+	Credentials *store.Credentials
+}
+
+// defaults returns the group and project of a url. Either may be nil.
+func defaults(item store.Url) (*store.Group, *store.Project, error) {
+	gs := store.NewGroupStore()
+	if err := gs.Open(); err != nil {
+		return nil, nil, err
+	}
+
+	ps := store.NewProjectStore()
+	if err := ps.Open(); err != nil {
+		return nil, nil, err
+	}
+
+	var group *store.Group
+	if item.Group != "" {
+		group, _ = gs.Get(item.Group)
+	}
+
+	name := item.Project
+	if name == "" && group != nil {
+		name = group.Project
+	}
+
+	var project *store.Project
+	if name != "" {
+		project, _ = ps.Get(name)
+	}
+
+	return group, project, nil
+}
+
+func resolve(item store.Url) (settings, error) {
+	s := settings{
+		Project:     item.Project,
+		Url:         item.Url,
+		Masks:       item.Masks,
+		Thresholds:  item.Thresholds,
+		Credentials: item.Credentials,
+		Viewport: store.Viewport{
+			Width:  capture.DefaultOptions.Width,
+			Height: capture.DefaultOptions.Height,
+			Scale:  capture.DefaultOptions.Scale,
+		},
+	}
+
+	group, project, err := defaults(item)
+	if err != nil {
+		return s, err
+	}
+
+	// The url's own settings first, then those of its group and project.
+	viewport := item.Viewport
+	var baseUrl string
+	for _, d := range []*store.Defaults{groupDefaults(group), projectDefaults(project)} {
+		if d == nil {
+			continue
+		}
+
+		if baseUrl == "" {
+			baseUrl = d.BaseUrl
+		}
+		if viewport == nil {
+			viewport = d.Viewport
+		}
+		if s.Thresholds.Warning == nil {
+			s.Thresholds.Warning = d.Thresholds.Warning
+		}
+		if s.Thresholds.Fail == nil {
+			s.Thresholds.Fail = d.Thresholds.Fail
+		}
+		if s.Credentials == nil {
+			s.Credentials = d.Credentials
+		}
+		s.Masks = append(d.Masks[:len(d.Masks):len(d.Masks)], s.Masks...)
+	}
+
+	if project != nil {
+		s.Project = project.Name
+	}
+	if viewport != nil {
+		s.Viewport = *viewport
+	}
+	if s.Viewport.Scale == 0 {
+		s.Viewport.Scale = 1
+	}
+
+	s.Url = resolveUrl(baseUrl, item.Url)
+
+	return s, nil
+}
+
+func groupDefaults(g *store.Group) *store.Defaults {
+	if g == nil {
+		return nil
+	}
+	return &g.Defaults
+}
+
+func projectDefaults(p *store.Project) *store.Defaults {
+	if p == nil {
+		return nil
+	}
+	return &p.Defaults
+}
+
+// resolveUrl resolves a url that is stored as a path against the base url.
+func resolveUrl(baseUrl, path string) string {
+	if baseUrl == "" {
+		return path
+	}
+
+	u, err := url.Parse(path)
+	if err != nil || u.IsAbs() {
+		return path
+	}
+
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return path
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// captureOptions returns the url to capture and the options to capture it
+// with. A baseUrl replaces the base url of the project, and the scheme and
+// host of urls that are stored with them. Credentials are only sent to the
+// host the url resolves to without a baseUrl, they are not meant for others.
+func captureOptions(item store.Url, baseUrl string) (string, capture.Options, error) {
+	opts := capture.DefaultOptions
+	opts.Format = item.Format
+	opts.Quality = item.Quality
+
+	s, err := resolve(item)
+	if err != nil {
+		return "", opts, err
+	}
+
+	home := s.Url
+	if baseUrl != "" {
+		s.Url = resolveUrl(baseUrl, urlPath(item.Url))
+	}
+	if u, err := url.Parse(s.Url); err != nil || !u.IsAbs() {
+		return "", opts, fmt.Errorf("%v is a path, but there is no base url for it", item.Url)
+	}
+
+	opts.Width = s.Viewport.Width
+	opts.Height = s.Viewport.Height
+	opts.Scale = s.Viewport.Scale
+
+	for _, m := range s.Masks {
+		if m.Selector != "" {
+			opts.Hide = append(opts.Hide, m.Selector)
+		}
+	}
+
+	if c := s.Credentials; c != nil && origin(home) != "" && origin(home) == origin(s.Url) {
+		opts.Headers = make(map[string]string)
+		for k, v := range c.Headers {
+			opts.Headers[k] = v
+		}
+		if c.Username != "" {
+			opts.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+		}
+	}
+
+	return s.Url, opts, nil
+}
+
+// origin returns the lower case scheme and host of an absolute url, and ""
+// for others.
+func origin(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+var maskColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+// maskImage blanks the rectangles of the masks, given in page pixels, in a
+// screenshot of a page that is pageWidth wide.
+func maskImage(img image.Image, masks []store.Mask, pageWidth float64) image.Image {
+	var rects []store.Rect
+	for _, m := range masks {
+		if m.Rect != nil {
+			rects = append(rects, *m.Rect)
+		}
+	}
+	if len(rects) == 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	masked := image.NewRGBA(b)
+	draw.Draw(masked, b, img, b.Min, draw.Src)
+
+	scale := 1.0
+	if pageWidth > 0 {
+		scale = float64(b.Dx()) / pageWidth
+	}
+
+	for _, r := range rects {
+		draw.Draw(masked, scaleRect(r, scale).Add(b.Min), image.NewUniform(maskColor), image.Point{}, draw.Src)
+	}
+
+	return masked
+}
+
+// Filter selects urls by project, group and tag. Empty fields match every
+// url.
+type Filter struct {
+	Project string `json:"project"`
+	Group   string `json:"group"`
+	Tag     string `json:"tag"`
+}
+
+func (f Filter) empty() bool {
+	return f.Project == "" && f.Group == "" && f.Tag == ""
+}
+
+// filterUrls returns the urls that match the filter. A url is part of the
+// project of its group when it has none of its own.
+func filterUrls(urls []store.Url, f Filter) ([]store.Url, error) {
+	if f.empty() {
+		return urls, nil
+	}
+
+	var groups = make(map[string]string)
+	if f.Project != "" {
+		gs := store.NewGroupStore()
+		if err := gs.Open(); err != nil {
+			return nil, err
+		}
+		for _, g := range gs.List() {
+			groups[g.Name] = g.Project
+		}
+	}
+
+	var matched []store.Url
+	for _, u := range urls {
+		if f.Group != "" && u.Group != f.Group {
+			continue
+		}
+		if f.Tag != "" && !hasTag(u, f.Tag) {
+			continue
+		}
+		if f.Project != "" {
+			project := u.Project
+			if project == "" {
+				project = groups[u.Group]
+			}
+			if project != f.Project {
+				continue
+			}
+		}
+		matched = append(matched, u)
+	}
+
+	return matched, nil
+}
+
+func hasTag(u store.Url, tag string) bool {
+	for _, t := range u.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/imgdiff"
+	"github.com/jvdanker/mug/store"
+	"image"
+	"net/http"
+	"strings"
+)
+
+func loadImages(id int) (*store.Url, image.Image, image.Image, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	item, err := fs.Get(id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	i1, i2, err := decodeImages(*item)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return item, i1, i2, nil
+}
+
+// decodeImages returns the reference and current screenshots of item with
+// its masks applied.
+func decodeImages(item store.Url) (image.Image, image.Image, error) {
+	if item.Reference == "" || item.Current == "" {
+		return nil, nil, store.HandlerError{"Missing reference or current image", http.StatusInternalServerError}
+	}
+
+	i1, err := decodeDataUri(item.Reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	i2, err := decodeDataUri(item.Current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := resolve(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	i1 = maskImage(i1, s.Masks, pageWidth(item.ReferenceDom))
+	i2 = maskImage(i2, s.Masks, pageWidth(item.CurrentDom))
+
+	return i1, i2, nil
+}
+
+// diffImages compares the screenshots the way the settings of item ask for.
+func diffImages(item store.Url, i1, i2 image.Image) (DiffResponse, error) {
+	if item.Align {
+		return alignDiff(item, i1, i2), nil
+	}
+
+	return metricDiff(item, item.Metric, i1, i2)
+}
+
+func pageWidth(dom *store.DomSnapshot) float64 {
+	if dom == nil {
+		return 0
+	}
+	return dom.Width
+}
+
+func metricDiff(item store.Url, name string, i1, i2 image.Image) (DiffResponse, error) {
+	if name == "" {
+		name = imgdiff.DefaultMetric
+	}
+
+	m, err := imgdiff.NewMetric(name, metricOptions(item))
+	if err != nil {
+		return DiffResponse{}, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	score, err := imgdiff.Evaluate(m, i1, i2, item.Threshold)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+
+	output := score.Output
+	if output == "" {
+		output = fmt.Sprintf("%s: %f (threshold %f)", score.Metric, score.Value, score.Threshold)
+	}
+
+	response := DiffResponse{
+		Output:  output,
+		Status:  score.Pass,
+		Score:   storeScore(score),
+		Regions: storeRegions(imgdiff.Regions(i1, i2, imgdiff.DefaultRegionOptions)),
+	}
+
+	return response, nil
+}
+
+func metricOptions(item store.Url) imgdiff.MetricOptions {
+	return imgdiff.MetricOptions{
+		Tolerance:     item.Tolerance,
+		ColorSpace:    imgdiff.ColorSpace(item.ColorSpace),
+		ColorDistance: item.ColorDistance,
+	}
+}
+
+func alignDiff(item store.Url, i1, i2 image.Image) DiffResponse {
+	al := imgdiff.Align(i1, i2, imgdiff.DefaultRegionOptions)
+
+	var output []string
+	var shifts []store.Shift
+	for _, s := range al.Shifts {
+		shifts = append(shifts, store.Shift{Axis: string(s.Axis), At: s.At, Offset: s.Offset})
+		output = append(output, s.String())
+	}
+	output = append(output, fmt.Sprintf("%d pixels differ after alignment", al.Pixels))
+
+	b := i2.Bounds()
+	score := store.Score{
+		Metric:    "align",
+		Pixels:    al.Pixels,
+		Total:     b.Dx() * b.Dy(),
+		Threshold: item.Threshold,
+	}
+	if score.Total > 0 {
+		score.Value = float64(score.Pixels*100) / float64(score.Total)
+	}
+	score.Pass = score.Value <= score.Threshold
+
+	return DiffResponse{
+		Output:  strings.Join(output, "\n"),
+		Status:  score.Pass,
+		Score:   score,
+		Regions: storeRegions(al.Regions),
+		Shifts:  shifts,
+	}
+}
+
+func storeScore(s imgdiff.Score) store.Score {
+	return store.Score{
+		Metric:    s.Metric,
+		Value:     s.Value,
+		Threshold: s.Threshold,
+		Pass:      s.Pass,
+		Pixels:    s.Pixels,
+		Total:     s.Total,
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"github.com/jvdanker/mug/discover"
+	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/transfer"
+	"net/http"
+	"time"
+)
+
+// DiscoverRequest finds the urls of a site in its sitemap, by crawling it,
+// or both.
+type DiscoverRequest struct {
+	Sitemap string `json:"sitemap"`
+	Crawl   string `json:"crawl"`
+	discover.CrawlOptions
+	// Url holds the settings for the added urls, e.g. their group, metric
+	// and thresholds.
+	Url transfer.Settings `json:"url"`
+	// DryRun only returns the urls that would be added.
+	DryRun bool `json:"dryRun"`
+}
+
+type DiscoverResponse struct {
+	// Found is the number of urls discovered, Urls those of them that were
+	// not stored yet.
+	Found int      `json:"found"`
+	Urls  []string `json:"urls"`
+	Ids   []int    `json:"ids"`
+}
+
+var discoverClient = &http.Client{Timeout: 30 * time.Second}
+
+// Discover adds the urls of a site that are not stored yet.
+func (a MugApi) Discover(req DiscoverRequest) (DiscoverResponse, error) {
+	var response DiscoverResponse
+
+	if req.Sitemap == "" && req.Crawl == "" {
+		return response, store.HandlerError{"sitemap or crawl is required", http.StatusBadRequest}
+	}
+	if err := validateSettings(req.Url.ToUrl()); err != nil {
+		return response, err
+	}
+
+	var found []string
+	if req.Sitemap != "" {
+		urls, err := discover.Sitemap(discoverClient, req.Sitemap, req.Limit)
+		if err != nil {
+			return response, store.HandlerError{err.Error(), http.StatusBadGateway}
+		}
+		found = append(found, urls...)
+	}
+	if req.Crawl != "" {
+		urls, err := discover.Crawl(discoverClient, req.Crawl, req.CrawlOptions)
+		if err != nil {
+			return response, store.HandlerError{err.Error(), http.StatusBadGateway}
+		}
+		found = append(found, urls...)
+	}
+
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return response, err
+	}
+
+	// Urls stored as a path are compared by the url they resolve to.
+	var existing []string
+	for _, item := range fs.List() {
+		s, err := resolve(item)
+		if err != nil {
+			return response, err
+		}
+		existing = append(existing, s.Url)
+	}
+
+	response.Urls = discover.New(found, existing)
+	response.Found = len(found)
+	if req.DryRun || len(response.Urls) == 0 {
+		return response, nil
+	}
+
+	var urls []store.Url
+	for _, u := range response.Urls {
+		item := req.Url.ToUrl()
+		item.Url = u
+		urls = append(urls, item)
+	}
+
+	response.Ids, err = a.addUrls(urls)
+	return response, err
+}
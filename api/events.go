@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/jvdanker/mug/store"
+)
+
+// newErrors returns the error events in current that did not occur in the
+// reference capture.
+func newErrors(reference, current []store.PageEvent) []store.PageEvent {
+	seen := make(map[store.PageEvent]bool)
+	for _, e := range reference {
+		seen[e] = true
+	}
+
+	var result []store.PageEvent
+	for _, e := range current {
+		if e.IsError() && !seen[e] {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
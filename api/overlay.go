@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/jvdanker/mug/imgdiff"
+	"github.com/jvdanker/mug/store"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	// Decoders for the other capture formats.
+	_ "golang.org/x/image/webp"
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+const dataUriPrefix = "data::image/png;base64,"
+
+var (
+	regionColor = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	domColor    = color.RGBA{0x00, 0x00, 0xff, 0xff}
+)
+
+// dataUriBytes returns the media type and the raw bytes of a data uri.
+func dataUriBytes(s string) (string, []byte, error) {
+	contentType := "image/png"
+	if i := strings.Index(s, ";base64,"); i >= 0 {
+		// Older PNG data uris have a double colon.
+		if t := strings.TrimLeft(strings.TrimPrefix(s[:i], "data:"), ":"); t != "" {
+			contentType = t
+		}
+		s = s[i+len(";base64,"):]
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	return contentType, b, err
+}
+
+// decodeDataUri decodes an image in any of the registered formats, whatever
+// the media type in the data uri says.
+func decodeDataUri(s string) (image.Image, error) {
+	_, b, err := dataUriBytes(s)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	return img, err
+}
+
+func encodeDataUri(img image.Image) (string, error) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		return "", err
+	}
+
+	return dataUri(store.PNG, buf.Bytes()), nil
+}
+
+// dataUri encodes an image in the given format. PNG images keep the prefix
+// that existing data was stored with.
+func dataUri(format store.ImageFormat, b []byte) string {
+	prefix := dataUriPrefix
+	if format != store.PNG && format != "" {
+		prefix = "data:image/" + string(format) + ";base64,"
+	}
+
+	return prefix + base64.StdEncoding.EncodeToString(b)
+}
+
+func storeRegions(r []imgdiff.Region) []store.Region {
+	var regions []store.Region
+	for _, r := range r {
+		regions = append(regions, store.Region{
+			Bounds: store.Rect{
+				X:      float64(r.Bounds.Min.X),
+				Y:      float64(r.Bounds.Min.Y),
+				Width:  float64(r.Bounds.Dx()),
+				Height: float64(r.Bounds.Dy()),
+			},
+			Pixels:   r.Pixels,
+			Severity: string(r.Severity),
+		})
+	}
+
+	return regions
+}
+
+// createOverlay outlines the changed regions and elements on top of the
+// current thumbnail. Regions are in thumbnail pixels, the bounds of the
+// changed elements are in page pixels and scaled down using pageWidth.
+func createOverlay(current string, pageWidth float64, regions []store.Region, changes []store.DomChange) (string, error) {
+	img, err := decodeDataUri(current)
+	if err != nil {
+		return "", err
+	}
+
+	b := img.Bounds()
+	overlay := image.NewRGBA(b)
+	draw.Draw(overlay, b, img, b.Min, draw.Src)
+
+	scale := 1.0
+	if pageWidth > 0 {
+		scale = float64(b.Dx()) / pageWidth
+	}
+
+	for _, c := range changes {
+		drawRect(overlay, scaleRect(c.Bounds, scale).Add(b.Min), domColor)
+	}
+
+	for _, r := range regions {
+		drawRect(overlay, scaleRect(r.Bounds, 1).Add(b.Min), regionColor)
+	}
+
+	return encodeDataUri(overlay)
+}
+
+func scaleRect(r store.Rect, scale float64) image.Rectangle {
+	return image.Rect(
+		int(r.X*scale),
+		int(r.Y*scale),
+		int((r.X+r.Width)*scale),
+		int((r.Y+r.Height)*scale))
+}
+
+func drawRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X-1, y, c)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jvdanker/mug/report"
+	"github.com/jvdanker/mug/store"
+	"strings"
+)
+
+// htmlReport zips a HTML report of the last scan, with the reference,
+// current and overlay images of every url.
+func htmlReport() (store.RawResponse, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return store.RawResponse{}, err
+	}
+
+	urls := fs.List()
+	cases := report.FromUrls(urls, nil)
+
+	var pages []report.Page
+	for i, u := range urls {
+		p := report.Page{
+			Case:    cases[i],
+			Url:     u.Url,
+			Metrics: urlMetrics(u),
+		}
+
+		if p.Reference, err = reportImage(u.Reference); err != nil {
+			return store.RawResponse{}, err
+		}
+		if p.Current, err = reportImage(u.Current); err != nil {
+			return store.RawResponse{}, err
+		}
+		if p.Overlay, err = reportImage(u.Overlay); err != nil {
+			return store.RawResponse{}, err
+		}
+
+		pages = append(pages, p)
+	}
+
+	buf := new(bytes.Buffer)
+	out := report.NewZipOutput(buf)
+	if err = report.HTML(out, "mug report", pages); err != nil {
+		return store.RawResponse{}, err
+	}
+	if err = out.Close(); err != nil {
+		return store.RawResponse{}, err
+	}
+
+	return store.RawResponse{
+		ContentType: "application/zip",
+		Filename:    "mug-report.zip",
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+func reportImage(dataUri string) (*report.Image, error) {
+	if dataUri == "" {
+		return nil, nil
+	}
+
+	contentType, data, err := dataUriBytes(dataUri)
+	if err != nil {
+		return nil, err
+	}
+
+	return &report.Image{
+		Data:   data,
+		Format: store.ImageFormat(strings.TrimPrefix(contentType, "image/")),
+	}, nil
+}
+
+func urlMetrics(u store.Url) []report.Metric {
+	if u.Results.Score.Metric == "" {
+		return nil
+	}
+
+	s := u.Results.Score
+	metrics := []report.Metric{
+		{Name: "Metric", Value: s.Metric},
+		{Name: "Score", Value: fmt.Sprintf("%f", s.Value)},
+		{Name: "Threshold", Value: fmt.Sprintf("%f", s.Threshold)},
+	}
+	if s.Total > 0 {
+		metrics = append(metrics, report.Metric{Name: "Pixels", Value: fmt.Sprintf("%d of %d (%.2f%%)", s.Pixels, s.Total, 100*float64(s.Pixels)/float64(s.Total))})
+	}
+	metrics = append(metrics,
+		report.Metric{Name: "Regions", Value: fmt.Sprint(len(u.Regions))},
+		report.Metric{Name: "Shifts", Value: fmt.Sprint(len(u.Shifts))},
+		report.Metric{Name: "DOM changes", Value: fmt.Sprint(len(u.DomChanges))},
+		report.Metric{Name: "New page errors", Value: fmt.Sprint(len(newErrors(u.ReferenceEvents, u.CurrentEvents)))},
+	)
+
+	return metrics
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/webhook"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runLock keeps the worker and new runs from overwriting each other's
+// changes to runs.json.
+var runLock sync.Mutex
+
+// StartRun scans the urls that match the filter as one run and returns it
+// right away. GetRun shows its progress and, once every url is scanned, its
+// results.
+func (a MugApi) StartRun(req ScanRequest) (store.Run, error) {
+	var t WorkType
+	switch req.Type {
+	case "current":
+		t = UpdateCurrent
+	case "reference":
+		t = UpdateReference
+	default:
+		return store.Run{}, store.HandlerError{"Unsupported type " + req.Type, http.StatusBadRequest}
+	}
+
+	if err := validBaseUrl(req.BaseUrl); err != nil {
+		return store.Run{}, err
+	}
+
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return store.Run{}, err
+	}
+
+	urls, err := filterUrls(fs.List(), req.Filter)
+	if err != nil {
+		return store.Run{}, err
+	}
+
+	runLock.Lock()
+	defer runLock.Unlock()
+
+	rs := store.NewRunStore()
+	err = rs.Open()
+	if err != nil {
+		return store.Run{}, err
+	}
+
+	max := 0
+	for _, r := range rs.List() {
+		if r.Id > max {
+			max = r.Id
+		}
+	}
+
+	run := store.Run{
+		Id:          max + 1,
+		Type:        req.Type,
+		Branch:      req.Branch,
+		Commit:      req.Commit,
+		TriggeredBy: req.TriggeredBy,
+		Labels:      req.Labels,
+		BaseUrl:     req.BaseUrl,
+		Project:     req.Project,
+		Group:       req.Group,
+		Tag:         req.Tag,
+		State:       store.RunRunning,
+		Total:       len(urls),
+		Created:     time.Now(),
+		Results:     []store.RunResult{},
+	}
+
+	var work []WorkItem
+	for _, item := range urls {
+		run.Results = append(run.Results, store.RunResult{Id: item.Id, Url: item.Url})
+		work = append(work, WorkItem{Type: t, Url: item, BaseUrl: req.BaseUrl, Run: run.Id})
+	}
+
+	if req.ServerUrl != "" {
+		run.Link = fmt.Sprintf("%s/run/%d", req.ServerUrl, run.Id)
+	}
+
+	if run.Total == 0 {
+		run.State = store.RunFinished
+		run.Finished = &run.Created
+	}
+
+	err = rs.Add(run)
+	if err != nil {
+		return store.Run{}, err
+	}
+
+	rs.Close()
+
+	if run.State == store.RunFinished {
+		publishRun(run)
+	} else {
+		postStatuses(run)
+	}
+	a.worker.submit(work...)
+
+	return run, nil
+}
+
+// finishRunItem records the outcome of the scan of item in its run. A
+// failed scan fails the url. The run is finished once all its urls are,
+// finished tells if this was the last one.
+func finishRunItem(runId int, id int, item store.Url, failure error) (run store.Run, finished bool, err error) {
+	runLock.Lock()
+	defer runLock.Unlock()
+
+	rs := store.NewRunStore()
+	err = rs.Open()
+	if err != nil {
+		return store.Run{}, false, err
+	}
+
+	r, err := rs.Get(runId)
+	if err != nil {
+		return store.Run{}, false, err
+	}
+	run = *r
+
+	for i := range run.Results {
+		r := &run.Results[i]
+		if r.Id != id || r.Done {
+			continue
+		}
+
+		// A reference scan has nothing to compare yet, the diff of the
+		// url is from before it.
+		r.Done = true
+		if run.Type == "current" {
+			r.Status = item.Status
+			r.Results = item.Results
+		}
+		if failure != nil {
+			r.Status = store.FAIL
+			r.Error = failure.Error()
+		}
+
+		run.Done++
+		if r.Status == store.FAIL {
+			run.Failed++
+		}
+		if r.Status > run.Status {
+			run.Status = r.Status
+		}
+		break
+	}
+
+	if run.Done == run.Total && run.State != store.RunFinished {
+		now := time.Now()
+		run.State = store.RunFinished
+		run.Finished = &now
+		finished = true
+	}
+
+	err = rs.Update(run)
+	if err != nil {
+		return store.Run{}, false, err
+	}
+
+	rs.Close()
+
+	return run, finished, nil
+}
+
+// publishRun tells the webhooks and the commit status providers that run
+// finished.
+func publishRun(run store.Run) {
+	e := webhook.NewEvent(webhook.RunFinished)
+	e.Run = &run
+	publish(e)
+
+	postStatuses(run)
+}
+
+// ListRuns returns the runs, newest first. Branch and commit select the runs
+// of a branch or commit when set.
+func (a MugApi) ListRuns(branch, commit string) ([]store.Run, error) {
+	rs := store.NewRunStore()
+	err := rs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []store.Run{}
+	all := rs.List()
+	for i := len(all) - 1; i >= 0; i-- {
+		r := all[i]
+		if branch != "" && r.Branch != branch {
+			continue
+		}
+		if commit != "" && r.Commit != commit {
+			continue
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, nil
+}
+
+func (a MugApi) GetRun(id int) (*store.Run, error) {
+	rs := store.NewRunStore()
+	err := rs.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := rs.Get(id)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jvdanker/mug/ci"
+	"github.com/jvdanker/mug/store"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func (a MugApi) ListCommitStatuses() ([]store.CommitStatus, error) {
+	ss := store.NewCommitStatusStore()
+	err := ss.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []store.CommitStatus{}
+	for _, c := range ss.List() {
+		statuses = append(statuses, c.Redacted())
+	}
+
+	return statuses, nil
+}
+
+// SaveCommitStatus adds a commit status provider, or changes the one with
+// the same id. The token and header values are never returned, a change
+// that leaves them empty keeps the stored ones.
+func (a MugApi) SaveCommitStatus(c store.CommitStatus) (store.CommitStatus, error) {
+	ss := store.NewCommitStatusStore()
+	err := ss.Open()
+	if err != nil {
+		return store.CommitStatus{}, err
+	}
+
+	if c.Id != 0 {
+		stored, err := ss.Get(c.Id)
+		if err != nil {
+			return store.CommitStatus{}, store.HandlerError{"", http.StatusNotFound}
+		}
+		c = c.Keep(*stored)
+	}
+
+	p, err := ci.New(c)
+	if err != nil {
+		return store.CommitStatus{}, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	now := time.Now()
+	run := store.Run{Id: 1, Type: "current", Commit: "0123456789abcdef", State: store.RunFinished, Created: now, Finished: &now}
+	if _, err = targetUrl(c, run); err != nil {
+		return store.CommitStatus{}, store.HandlerError{"Target url: " + err.Error(), http.StatusBadRequest}
+	}
+	if h, ok := p.(ci.HTTP); ok {
+		if _, _, _, err = h.Request(ci.FromRun(run, c.Context, "")); err != nil {
+			return store.CommitStatus{}, store.HandlerError{err.Error(), http.StatusBadRequest}
+		}
+	}
+
+	if c.Project != "" {
+		ps := store.NewProjectStore()
+		err = ps.Open()
+		if err != nil {
+			return store.CommitStatus{}, err
+		}
+
+		if _, err = ps.Get(c.Project); err != nil {
+			return store.CommitStatus{}, store.HandlerError{"Unknown project " + c.Project, http.StatusBadRequest}
+		}
+	}
+
+	if c.Id == 0 {
+		for _, s := range ss.List() {
+			if s.Id > c.Id {
+				c.Id = s.Id
+			}
+		}
+		c.Id++
+	}
+
+	err = ss.Save(c)
+	if err != nil {
+		return store.CommitStatus{}, err
+	}
+
+	ss.Close()
+
+	return c.Redacted(), nil
+}
+
+func (a MugApi) DeleteCommitStatus(id int) (interface{}, error) {
+	ss := store.NewCommitStatusStore()
+	err := ss.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ss.Delete(id)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	ss.Close()
+
+	return nil, nil
+}
+
+// Statuses are posted one at a time and in order, so that the pending
+// status of a run can't arrive after its outcome.
+var (
+	statusQueue      = make(chan store.Run, 100)
+	startStatusQueue sync.Once
+)
+
+// postStatuses posts the state of run as a commit status with the
+// providers of its project, in the background. Runs without a commit
+// weren't started by CI and aren't posted.
+func postStatuses(run store.Run) {
+	if run.Commit == "" {
+		return
+	}
+
+	startStatusQueue.Do(func() {
+		go postQueuedStatuses()
+	})
+	statusQueue <- run
+}
+
+func postQueuedStatuses() {
+	for run := range statusQueue {
+		ss := store.NewCommitStatusStore()
+		err := ss.Open()
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		for _, c := range ss.List() {
+			if c.Project != "" && c.Project != run.Project {
+				continue
+			}
+
+			if err = postStatus(c, run); err != nil {
+				fmt.Printf("commit status %d of run %d: %v\n", c.Id, run.Id, err)
+			}
+		}
+	}
+}
+
+func postStatus(c store.CommitStatus, run store.Run) error {
+	p, err := ci.New(c)
+	if err != nil {
+		return err
+	}
+
+	link, err := targetUrl(c, run)
+	if err != nil {
+		return err
+	}
+
+	return p.Post(ci.FromRun(run, c.Context, link))
+}
+
+// targetUrl is the link in the status of run: the TargetUrl template of the
+// run, or where the run can be fetched.
+func targetUrl(c store.CommitStatus, run store.Run) (string, error) {
+	if c.TargetUrl == "" {
+		return run.Link, nil
+	}
+
+	t, err := ci.Template("targetUrl", c.TargetUrl)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, run)
+	return buf.String(), err
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/jvdanker/mug/store"
+)
+
+// evaluateStatus maps a diff score to a status using the thresholds of the
+// url, falling back to the defaults of its group and project. Without any
+// thresholds the metric's own threshold decides between SUCCESS and FAIL.
+func evaluateStatus(item store.Url, score store.Score) (store.StatusType, error) {
+	t, err := thresholds(item)
+	if err != nil {
+		return store.FAIL, err
+	}
+
+	if t.Warning == nil && t.Fail == nil {
+		if score.Pass {
+			return store.SUCCESS, nil
+		}
+		return store.FAIL, nil
+	}
+
+	if exceeds(t.Fail, score) {
+		return store.FAIL, nil
+	}
+
+	if exceeds(t.Warning, score) {
+		return store.WARNING, nil
+	}
+
+	return store.SUCCESS, nil
+}
+
+func thresholds(item store.Url) (store.Thresholds, error) {
+	s, err := resolve(item)
+	return s.Thresholds, err
+}
+
+func exceeds(l *store.Limits, s store.Score) bool {
+	if l == nil {
+		return false
+	}
+
+	if l.MaxPixels != nil && s.Pixels > *l.MaxPixels {
+		return true
+	}
+
+	if l.MaxPercent != nil && s.Total > 0 && float64(s.Pixels*100)/float64(s.Total) > *l.MaxPercent {
+		return true
+	}
+
+	if l.MaxScore != nil && s.Value > *l.MaxScore {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/webhook"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// webhookSender delivers the events, it retries failed deliveries.
+var webhookSender = webhook.DefaultSender
+
+// deliveryLock keeps deliveries that finish at the same time from
+// overwriting each other in the delivery log.
+var deliveryLock sync.Mutex
+
+var webhookEvents = map[NotificationType]string{
+	ReferenceUpdated: webhook.ReferenceUpdated,
+	CurrentUpdated:   webhook.CurrentUpdated,
+	DiffUpdated:      webhook.DiffUpdated,
+}
+
+func (a MugApi) ListWebhooks() ([]store.Webhook, error) {
+	ws := store.NewWebhookStore()
+	err := ws.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []store.Webhook{}
+	for _, h := range ws.List() {
+		webhooks = append(webhooks, h.Redacted())
+	}
+
+	return webhooks, nil
+}
+
+// SaveWebhook adds a webhook, or changes the one with the same id. The
+// secret is never returned, a change without one keeps the stored secret.
+func (a MugApi) SaveWebhook(h store.Webhook) (store.Webhook, error) {
+	u, err := url.Parse(h.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return store.Webhook{}, store.HandlerError{"Webhook url must be an absolute http(s) url", http.StatusBadRequest}
+	}
+
+	if err = webhook.Valid(h.Events); err != nil {
+		return store.Webhook{}, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	// The template has to work for every event it gets.
+	events := h.Events
+	if len(events) == 0 {
+		events = webhook.Events
+	}
+	for _, t := range events {
+		if _, err = webhook.Render(h.Template, webhook.Sample(t)); err != nil {
+			return store.Webhook{}, store.HandlerError{fmt.Sprintf("Template: %v: %v", t, err), http.StatusBadRequest}
+		}
+	}
+
+	ws := store.NewWebhookStore()
+	err = ws.Open()
+	if err != nil {
+		return store.Webhook{}, err
+	}
+
+	if h.Id == 0 {
+		for _, w := range ws.List() {
+			if w.Id > h.Id {
+				h.Id = w.Id
+			}
+		}
+		h.Id++
+	} else {
+		stored, err := ws.Get(h.Id)
+		if err != nil {
+			return store.Webhook{}, store.HandlerError{"", http.StatusNotFound}
+		}
+		if h.Secret == "" {
+			h.Secret = stored.Secret
+		}
+	}
+
+	err = ws.Save(h)
+	if err != nil {
+		return store.Webhook{}, err
+	}
+
+	ws.Close()
+
+	return h.Redacted(), nil
+}
+
+func (a MugApi) DeleteWebhook(id int) (interface{}, error) {
+	ws := store.NewWebhookStore()
+	err := ws.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ws.Delete(id)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	ws.Close()
+
+	return nil, nil
+}
+
+// TestWebhook delivers a sample event of type event, or of the first type
+// the webhook receives, and waits for the outcome.
+func (a MugApi) TestWebhook(id int, event string) ([]store.Delivery, error) {
+	ws := store.NewWebhookStore()
+	err := ws.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := ws.Get(id)
+	if err != nil {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	if event == "" {
+		event = webhook.DiffUpdated
+		if len(h.Events) > 0 {
+			event = h.Events[0]
+		}
+	}
+	if err = webhook.Valid([]string{event}); err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	return deliver(*h, webhook.Sample(event)), nil
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first.
+func (a MugApi) ListDeliveries(id int) ([]store.Delivery, error) {
+	ds := store.NewDeliveryStore()
+	err := ds.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []store.Delivery{}
+	all := ds.List()
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Webhook == id {
+			deliveries = append(deliveries, all[i])
+		}
+	}
+
+	return deliveries, nil
+}
+
+// publish delivers e to the webhooks that subscribe to it, in the
+// background.
+func publish(e webhook.Event) {
+	go func() {
+		ws := store.NewWebhookStore()
+		err := ws.Open()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, h := range ws.List() {
+			if webhook.Matches(h.Events, e) {
+				go deliver(h, e)
+			}
+		}
+	}()
+}
+
+// deliver sends e to h and logs every attempt.
+func deliver(h store.Webhook, e webhook.Event) []store.Delivery {
+	var deliveries []store.Delivery
+	log := func(a webhook.Attempt) {
+		d := store.Delivery{
+			Id:         a.Id,
+			Webhook:    h.Id,
+			Event:      e.Type,
+			Attempt:    a.Attempt,
+			StatusCode: a.StatusCode,
+			Success:    a.Success(),
+			Time:       a.Time,
+			Duration:   a.Duration.Seconds(),
+		}
+		if a.Err != nil {
+			d.Error = a.Err.Error()
+		}
+
+		deliveries = append(deliveries, d)
+		if err := logDelivery(d); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	body, err := webhook.Render(h.Template, e)
+	if err != nil {
+		log(webhook.Attempt{Attempt: 1, Err: err, Time: e.Time})
+		return deliveries
+	}
+
+	webhookSender.Send(h.Url, h.Secret, e.Type, body, log)
+
+	return deliveries
+}
+
+func logDelivery(d store.Delivery) error {
+	deliveryLock.Lock()
+	defer deliveryLock.Unlock()
+
+	ds := store.NewDeliveryStore()
+	err := ds.Open()
+	if err != nil {
+		return err
+	}
+
+	err = ds.Add(d)
+	if err != nil {
+		return err
+	}
+
+	ds.Close()
+
+	return nil
+}
+
+// urlEvent is an event about item. A failure is the error that failed its
+// scan.
+func urlEvent(t string, item store.Url, run int, failure error) webhook.Event {
+	e := webhook.NewEvent(t)
+	e.Url = &webhook.Url{
+		Id:      item.Id,
+		Url:     item.Url,
+		Status:  item.Status,
+		Results: item.Results,
+		Run:     run,
+	}
+	if failure != nil {
+		e.Url.Status = store.FAIL
+		e.Url.Error = failure.Error()
+	}
+
+	return e
+}
@@ -3,7 +3,10 @@ package api
 import (
 	"context"
 	"fmt"
+	"github.com/jvdanker/mug/capture"
+	"github.com/jvdanker/mug/domdiff"
 	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/webhook"
 	"sync"
 )
 
@@ -11,6 +14,12 @@ type WorkType int
 type WorkItem struct {
 	Type WorkType
 	Url  store.Url
+	// BaseUrl replaces the base url of the url's project for this scan.
+	BaseUrl string
+	// Run is the id of the run the scan is part of, 0 if it isn't.
+	Run int
+	// Comparison is the id of the comparison a CompareUrl is part of.
+	Comparison int
 }
 
 const (
@@ -18,6 +27,7 @@ const (
 	UpdateCurrent
 	NewUrl
 	UpdateDiff
+	CompareUrl
 )
 
 type NotificationType int
@@ -34,77 +44,102 @@ const (
 )
 
 type Worker struct {
-	c chan WorkItem
-	u chan NotificationItem
+	c        chan WorkItem
+	u        chan NotificationItem
+	capturer capture.Capturer
 }
 
-func NewWorker() Worker {
+func NewWorker(capturer capture.Capturer) Worker {
 	var work = make(chan WorkItem, 100)
 	var updates = make(chan NotificationItem, 100)
 
 	return Worker{
-		c: work,
-		u: updates,
+		c:        work,
+		u:        updates,
+		capturer: capturer,
 	}
 }
 
+// applyDiff stores the outcome of a diff in item: the results, the status
+// that its thresholds and error policy give it, the DOM changes and the
+// overlay.
+func applyDiff(item *store.Url, resp DiffResponse) error {
+	var err error
+
+	item.Results = store.Results{Summary: resp.Output, Score: resp.Score}
+	item.Regions = resp.Regions
+	item.Shifts = resp.Shifts
+	item.Status, err = evaluateStatus(*item, resp.Score)
+	if err != nil {
+		return err
+	}
+
+	if len(newErrors(item.ReferenceEvents, item.CurrentEvents)) > 0 {
+		switch item.ErrorPolicy {
+		case store.WarnOnErrors:
+			if item.Status == store.SUCCESS {
+				item.Status = store.WARNING
+			}
+		case store.FailOnErrors:
+			item.Status = store.FAIL
+		}
+	}
+
+	width := 0.0
+	if item.ReferenceDom != nil && item.CurrentDom != nil {
+		item.DomChanges = domdiff.Diff(*item.ReferenceDom, *item.CurrentDom)
+		width = item.CurrentDom.Width
+	}
+
+	item.Overlay, err = createOverlay(item.Current, width, item.Regions, item.DomChanges)
+	return err
+}
+
+// submit queues work without blocking. The queue holds 100 items, while a
+// scan or import can add thousands and the worker queues follow-up work
+// itself.
+func (w Worker) submit(items ...WorkItem) {
+	go func() {
+		for _, item := range items {
+			w.c <- item
+		}
+	}()
+}
+
 func (w Worker) Worker(ctx context.Context, wg sync.WaitGroup) {
 	fmt.Println("Listening for work...")
 loop:
 	for {
 		select {
 		case work := <-w.c:
-			fmt.Println("work received %v", work)
+			fmt.Println("work received", work.Type, work.Url.Id)
 
-			fs := store.NewFileStore()
-			err := fs.Open()
-			if err != nil {
-				panic(err)
+			if work.Type == CompareUrl {
+				w.compare(work)
+				continue
 			}
 
-			item, err := fs.Get(work.Url.Id)
+			item, err := w.process(work)
 			if err != nil {
-				panic(err)
-			}
-
-			if work.Type == UpdateDiff {
-				a := NewApi(w)
-				resp, err := a.PDiff(work.Url.Id)
-				if err != nil {
-					panic(err)
+				fmt.Println(err)
+				item.Id = work.Url.Id
+				if item.Url == "" {
+					item.Url = work.Url.Url
 				}
+				publish(urlEvent(webhook.Failure, item, work.Run, err))
+			}
 
-				item.Results = resp.Output
-				if resp.Status {
-					item.Status = store.SUCCESS
-				} else {
-					item.Status = store.FAIL
-				}
-				w.u <- NotificationItem{Type: DiffUpdated, Id: work.Url.Id, Data: *item}
-
-			} else {
-				_, thumb, err := CreateScreenshot(item.Url)
+			// A scan in a run ends with its diff, or with its reference for
+			// reference scans, or when it fails.
+			if work.Run != 0 && (err != nil || work.Type == UpdateDiff || work.Type == UpdateReference) {
+				run, finished, err := finishRunItem(work.Run, work.Url.Id, item, err)
 				if err != nil {
-					panic(err)
-				}
-
-				switch work.Type {
-				case NewUrl:
-					item.Reference = thumb
-					w.c <- WorkItem{Type: UpdateCurrent, Url: *item}
-					w.u <- NotificationItem{Type: ReferenceUpdated, Id: work.Url.Id, Data: *item}
-				case UpdateReference:
-					item.Reference = thumb
-					w.u <- NotificationItem{Type: ReferenceUpdated, Id: work.Url.Id, Data: *item}
-				case UpdateCurrent:
-					item.Current = thumb
-					w.c <- WorkItem{Type: UpdateDiff, Url: *item}
-					w.u <- NotificationItem{Type: CurrentUpdated, Id: work.Url.Id, Data: *item}
+					fmt.Println(err)
+				} else if finished {
+					publishRun(run)
 				}
 			}
 
-			fs.Close()
-
 		case <-ctx.Done():
 			fmt.Println("ctx done")
 			break loop
@@ -113,3 +148,91 @@ loop:
 	fmt.Println("Done listening for work...")
 	wg.Done()
 }
+
+// notify tells the web UI and the webhooks that item changed. A diff that
+// fails the url is a failure as well.
+func (w Worker) notify(t NotificationType, work WorkItem, item store.Url) {
+	w.update(NotificationItem{Type: t, Id: work.Url.Id, Data: item.Redacted()})
+
+	publish(urlEvent(webhookEvents[t], item, work.Run, nil))
+	if t == DiffUpdated && item.Status == store.FAIL {
+		publish(urlEvent(webhook.Failure, item, work.Run, nil))
+	}
+}
+
+// update queues an update for the web UI without blocking. Nobody may be
+// polling the updates, when the queue is full the oldest update is dropped
+// instead of stalling the work.
+func (w Worker) update(n NotificationItem) {
+	for {
+		select {
+		case w.u <- n:
+			return
+		default:
+		}
+
+		select {
+		case <-w.u:
+		default:
+		}
+	}
+}
+
+// process does one piece of work and returns the url as it is stored after
+// it. Failed work leaves the url as it was.
+func (w Worker) process(work WorkItem) (store.Url, error) {
+	fs := store.NewFileStore()
+	err := fs.Open()
+	if err != nil {
+		return store.Url{}, err
+	}
+
+	item, err := fs.Get(work.Url.Id)
+	if err != nil {
+		return store.Url{}, err
+	}
+
+	if work.Type == UpdateDiff {
+		a := NewApi(w)
+		resp, err := a.Diff(work.Url.Id)
+		if err != nil {
+			return *item, err
+		}
+
+		err = applyDiff(item, resp)
+		if err != nil {
+			return *item, err
+		}
+		w.notify(DiffUpdated, work, *item)
+
+	} else {
+		screenshot, err := CreateScreenshot(w.capturer, *item, work.BaseUrl)
+		if err != nil {
+			return *item, err
+		}
+
+		switch work.Type {
+		case NewUrl:
+			item.Reference = screenshot.Thumbnail
+			item.ReferenceEvents = screenshot.Events
+			item.ReferenceDom = &screenshot.Dom
+			w.submit(WorkItem{Type: UpdateCurrent, Url: *item, BaseUrl: work.BaseUrl, Run: work.Run})
+			w.notify(ReferenceUpdated, work, *item)
+		case UpdateReference:
+			item.Reference = screenshot.Thumbnail
+			item.ReferenceEvents = screenshot.Events
+			item.ReferenceDom = &screenshot.Dom
+			w.notify(ReferenceUpdated, work, *item)
+		case UpdateCurrent:
+			item.Current = screenshot.Thumbnail
+			item.CurrentEvents = screenshot.Events
+			item.CurrentDom = &screenshot.Dom
+			w.submit(WorkItem{Type: UpdateDiff, Url: *item, Run: work.Run})
+			w.notify(CurrentUpdated, work, *item)
+		}
+	}
+
+	fs.Close()
+
+	return *item, nil
+}
@@ -0,0 +1,241 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Entry is one url to capture. Name is optional and can be used in the
+// naming template.
+type Entry struct {
+	Url  string `yaml:"url"`
+	Name string `yaml:"name"`
+}
+
+// Load reads the urls to capture from a file. The format follows the
+// extension: YAML (.yaml, .yml) holds a list of urls or of {url, name}
+// objects, CSV (.csv) has a url and an optional name column and anything
+// else is read as text with one url per line. Empty lines and lines starting
+// with # are skipped in text files.
+func Load(filename string) ([]Entry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		entries, err = parseYAML(data)
+	case ".csv":
+		entries, err = parseCSV(bytes.NewReader(data))
+	default:
+		entries, err = parseText(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+
+	for i, e := range entries {
+		if e.Url == "" {
+			return nil, fmt.Errorf("%v: entry %d has no url", filename, i+1)
+		}
+	}
+
+	return entries, nil
+}
+
+func parseText(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, Entry{Url: line})
+	}
+
+	return entries, scanner.Err()
+}
+
+func parseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the header row, if there is one.
+	if len(records) > 0 && strings.EqualFold(records[0][0], "url") {
+		records = records[1:]
+	}
+
+	var entries []Entry
+	for _, record := range records {
+		e := Entry{Url: record[0]}
+		if len(record) > 1 {
+			e.Name = record[1]
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseYAML(data []byte) ([]Entry, error) {
+	var entries []Entry
+	err := yaml.Unmarshal(data, &entries)
+	return entries, err
+}
+
+// UnmarshalYAML accepts a plain url as well as a {url, name} object.
+func (e *Entry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&e.Url); err == nil {
+		return nil
+	}
+
+	type entry Entry
+	return unmarshal((*entry)(e))
+}
+
+// DefaultTemplate names files after the host and path of the url.
+const DefaultTemplate = "{{.Slug}}.{{.Ext}}"
+
+// Names are the fields available in a naming template.
+type Names struct {
+	// Index is the 1-based position of the url in the input.
+	Index int
+	Url   string
+	Name  string
+	Host  string
+	Path  string
+	// Slug is the name, or the host and path of the url, made safe for a
+	// filename.
+	Slug string
+	Ext  string
+}
+
+var unsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func slug(s string) string {
+	s = strings.Trim(unsafe.ReplaceAllString(s, "-"), "-.")
+	if s == "" {
+		return "index"
+	}
+	return s
+}
+
+// Filenames expands the naming template for every entry. It fails when two
+// entries end up with the same filename, so that captures don't overwrite
+// each other.
+func Filenames(tmpl string, entries []Entry, ext string) ([]string, error) {
+	t, err := template.New("name").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]int)
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		n := Names{
+			Index: i + 1,
+			Url:   e.Url,
+			Name:  e.Name,
+			Ext:   ext,
+		}
+
+		if u, err := url.Parse(e.Url); err == nil {
+			n.Host = u.Host
+			n.Path = u.Path
+		}
+
+		n.Slug = slug(e.Name)
+		if e.Name == "" {
+			n.Slug = slug(n.Host + n.Path)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := t.Execute(buf, n); err != nil {
+			return nil, err
+		}
+
+		name := buf.String()
+		if j, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%v and %v would both be saved as %v", entries[j].Url, e.Url, name)
+		}
+		seen[name] = i
+		names[i] = name
+	}
+
+	return names, nil
+}
+
+// Result is the outcome of capturing one entry.
+type Result struct {
+	Entry    Entry
+	Filename string
+	Err      error
+}
+
+// Run calls capture for every entry, with its index, with at most
+// parallelism calls running at the same time. Results are returned in the
+// order of the entries.
+func Run(entries []Entry, filenames []string, parallelism int, capture func(i int, e Entry, filename string) error) []Result {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	results := make([]Result, len(entries))
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = Result{
+					Entry:    entries[i],
+					Filename: filenames[i],
+					Err:      capture(i, entries[i], filenames[i]),
+				}
+			}
+		}()
+	}
+
+	for i := range entries {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return results
+}
+
+// Failed returns the results that have an error.
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
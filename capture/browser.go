@@ -0,0 +1,209 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mafredri/cdp/devtool"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// BrowserOptions control how Chrome is started.
+type BrowserOptions struct {
+	// Path to the Chrome binary, found automatically when empty.
+	Path     string
+	Headless bool
+	// Width and Height of the window.
+	Width  int
+	Height int
+	// Port for the DevTools protocol, 0 picks a free port.
+	Port int
+	// UserDataDir is the profile directory. When empty a temporary profile
+	// is used and removed when the browser is closed.
+	UserDataDir string
+	// Flags are passed to Chrome as they are, e.g. --lang=nl.
+	Flags []string
+}
+
+var DefaultBrowserOptions = BrowserOptions{
+	Headless: true,
+	Width:    DefaultOptions.Width,
+	Height:   DefaultOptions.Height,
+}
+
+// Flags that keep captures the same regardless of the profile.
+var defaultFlags = []string{
+	"--disable-extensions",
+	"--disable-default-apps",
+	"--disable-sync",
+	"--hide-scrollbars",
+	"--incognito",
+	"--no-first-run",
+	"--no-default-browser-check",
+}
+
+// Browser is a Chrome process started by Launch.
+type Browser struct {
+	// DevTools is the endpoint to pass to NewChrome.
+	DevTools string
+
+	cmd     *exec.Cmd
+	tempDir string
+	done    chan error
+}
+
+// FindChrome returns the path of the Chrome binary on this system.
+func FindChrome() (string, error) {
+	var candidates []string
+	switch runtime.GOOS {
+	case "linux":
+		candidates = []string{"/opt/google/chrome/chrome"}
+	case "darwin":
+		candidates = []string{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"}
+	case "windows":
+		candidates = []string{`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`, `C:\Program Files\Google\Chrome\Application\chrome.exe`}
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "chrome"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", errors.New("chrome not found, set its path")
+}
+
+// Launch starts Chrome and waits until it accepts DevTools connections.
+func Launch(opts BrowserOptions) (*Browser, error) {
+	path := opts.Path
+	if path == "" {
+		var err error
+		if path, err = FindChrome(); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &Browser{
+		done: make(chan error, 1),
+	}
+
+	dir := opts.UserDataDir
+	if dir == "" {
+		var err error
+		if dir, err = ioutil.TempDir("", "mug-chrome"); err != nil {
+			return nil, err
+		}
+		b.tempDir = dir
+	}
+
+	// Chrome writes the port it listens on to this file.
+	portFile := filepath.Join(dir, "DevToolsActivePort")
+	os.Remove(portFile)
+
+	args := append([]string{
+		fmt.Sprintf("--remote-debugging-port=%d", opts.Port),
+		fmt.Sprintf("--window-size=%d,%d", opts.Width, opts.Height),
+		"--user-data-dir=" + dir,
+	}, defaultFlags...)
+	if opts.Headless {
+		args = append(args, "--headless", "--disable-gpu")
+	}
+	args = append(args, opts.Flags...)
+	args = append(args, "about:blank")
+
+	b.cmd = exec.Command(path, args...)
+	if err := b.cmd.Start(); err != nil {
+		b.removeTempDir()
+		return nil, err
+	}
+	go func() { b.done <- b.cmd.Wait() }()
+
+	port, err := waitForPort(portFile, b.done, 20*time.Second)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	b.DevTools = fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err = devtool.New(b.DevTools).Version(ctx); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func waitForPort(filename string, done <-chan error, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-done:
+			return 0, fmt.Errorf("chrome exited: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		f, err := os.Open(filename)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		var port int
+		if scanner.Scan() {
+			_, err = fmt.Sscanf(strings.TrimSpace(scanner.Text()), "%d", &port)
+		}
+		f.Close()
+
+		if err == nil && port > 0 {
+			return port, nil
+		}
+	}
+
+	return 0, errors.New("timeout waiting for chrome to start")
+}
+
+// Wait blocks until Chrome exits.
+func (b *Browser) Wait() error {
+	err := <-b.done
+	b.done <- err
+	b.removeTempDir()
+	return err
+}
+
+// Close stops Chrome.
+func (b *Browser) Close() error {
+	if b.cmd.Process != nil {
+		b.cmd.Process.Kill()
+	}
+
+	select {
+	case err := <-b.done:
+		b.done <- err
+	case <-time.After(5 * time.Second):
+	}
+
+	return b.removeTempDir()
+}
+
+func (b *Browser) removeTempDir() error {
+	if b.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(b.tempDir)
+}
@@ -0,0 +1,451 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/devtool"
+	"github.com/mafredri/cdp/protocol/dom"
+	"github.com/mafredri/cdp/protocol/emulation"
+	"github.com/mafredri/cdp/protocol/fetch"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/mafredri/cdp/rpcc"
+	"github.com/nfnt/resize"
+	"image"
+	"image/png"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDevTools is the DevTools endpoint of the Chrome that the server
+// starts.
+const DefaultDevTools = "http://127.0.0.1:9222"
+
+// Options control how a page is captured. Captures of the same url made
+// with the same options are comparable, whether the CLI or the server made
+// them.
+type Options struct {
+	// Width is the width of the page in CSS pixels, Height the height of the
+	// viewport while the page loads. The screenshot covers the whole page.
+	Width  int
+	Height int
+	// Scale is the device scale factor.
+	Scale float64
+	// UserAgent overrides the browser's user agent when set.
+	UserAgent string
+	// Timeout limits loading and capturing the page.
+	Timeout time.Duration
+	// WaitFor is a CSS selector. Once the page has loaded, the capture waits
+	// until the element it matches is visible.
+	WaitFor string
+	// Delay is extra time to wait once the page is ready.
+	Delay time.Duration
+	// Format and Quality (0-100, JPEG and WebP only) of the screenshot.
+	Format  store.ImageFormat
+	Quality int
+	// ImageWidth scales the screenshot down to this width, 0 keeps the full
+	// size.
+	ImageWidth int
+	// Headers are sent with the requests to the origin of the captured url,
+	// e.g. an Authorization header.
+	Headers map[string]string
+	// Hide are CSS selectors of elements that are hidden before the capture.
+	Hide []string
+}
+
+var DefaultOptions = Options{
+	Width:   1024,
+	Height:  768,
+	Scale:   1,
+	Timeout: 30 * time.Second,
+	Format:  store.PNG,
+}
+
+// Result is a captured page.
+type Result struct {
+	Image  []byte
+	Format store.ImageFormat
+	Events []store.PageEvent
+	Dom    store.DomSnapshot
+}
+
+type Capturer interface {
+	Capture(ctx context.Context, url string, opts Options) (Result, error)
+}
+
+// Chrome captures pages in a Chrome that listens for the DevTools protocol.
+// Every capture opens its own tab, so captures can run concurrently.
+type Chrome struct {
+	DevTools string
+}
+
+func NewChrome(devTools string) Chrome {
+	return Chrome{
+		DevTools: devTools,
+	}
+}
+
+// ValidFormat checks a screenshot format and quality.
+func ValidFormat(format store.ImageFormat, quality int) error {
+	switch format {
+	case "", store.PNG, store.JPEG, store.WebP:
+	default:
+		return fmt.Errorf("unknown image format %q", format)
+	}
+
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("quality must be between 0 and 100, got %d", quality)
+	}
+
+	return nil
+}
+
+func (c Chrome) Capture(ctx context.Context, url string, opts Options) (Result, error) {
+	if opts.Format == "" {
+		opts.Format = store.PNG
+	}
+	if opts.Scale == 0 {
+		opts.Scale = 1
+	}
+	if err := ValidFormat(opts.Format, opts.Quality); err != nil {
+		return Result{}, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// Use the DevTools HTTP/JSON API to open a tab of our own.
+	devt := devtool.New(c.DevTools)
+	pt, err := devt.Create(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer devt.Close(context.Background(), pt)
+
+	// Initiate a new RPC connection to the Chrome Debugging Protocol target.
+	conn, err := rpcc.DialContext(ctx, pt.WebSocketDebuggerURL)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close() // Leaving connections open will leak memory.
+
+	client := cdp.NewClient(conn)
+
+	if opts.UserAgent != "" {
+		err = client.Network.SetUserAgentOverride(ctx, network.NewSetUserAgentOverrideArgs(opts.UserAgent))
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	// Lay the page out at the capture width from the start.
+	err = client.Emulation.SetDeviceMetricsOverride(ctx, emulation.NewSetDeviceMetricsOverrideArgs(opts.Width, opts.Height, opts.Scale, false))
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(opts.Headers) > 0 {
+		if err = addHeaders(ctx, client, url, opts.Headers); err != nil {
+			return Result{}, err
+		}
+	}
+
+	events, err := load(ctx, client, url, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(opts.Hide) > 0 {
+		if err = hide(ctx, client, opts.Hide); err != nil {
+			return Result{}, err
+		}
+	}
+
+	// Fetch the document root node. We can pass nil here
+	// since this method only takes optional arguments.
+	doc, err := client.DOM.GetDocument(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+
+	qsr, err := client.DOM.QuerySelector(ctx, dom.NewQuerySelectorArgs(doc.Root.NodeID, "body"))
+	if err != nil {
+		return Result{}, err
+	}
+
+	bmr, err := client.DOM.GetBoxModel(ctx, dom.NewGetBoxModelArgs().SetNodeID(qsr.NodeID))
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Resize the viewport to the whole page.
+	err = client.Emulation.SetDeviceMetricsOverride(ctx, emulation.NewSetDeviceMetricsOverrideArgs(opts.Width, bmr.Model.Height, opts.Scale, false))
+	if err != nil {
+		return Result{}, err
+	}
+
+	snapshot, err := captureDom(ctx, client)
+	if err != nil {
+		return Result{}, err
+	}
+
+	data, err := screenshot(ctx, client, opts, bmr.Model.Height)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Image:  data,
+		Format: opts.Format,
+		Events: events.Stop(),
+		Dom:    snapshot,
+	}, nil
+}
+
+// addHeaders adds headers to the requests of the page that go to the origin
+// of target. Requests to other hosts, e.g. for third-party scripts, are sent
+// as they are, so credentials don't leak to them. Requests are intercepted
+// until the connection closes.
+func addHeaders(ctx context.Context, c *cdp.Client, target string, headers map[string]string) error {
+	origin, err := originOf(target)
+	if err != nil {
+		return err
+	}
+
+	paused, err := c.Fetch.RequestPaused(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = c.Fetch.Enable(ctx, fetch.NewEnableArgs()); err != nil {
+		paused.Close()
+		return err
+	}
+
+	go func() {
+		defer paused.Close()
+		for {
+			ev, err := paused.Recv()
+			if err != nil {
+				return
+			}
+
+			args := fetch.NewContinueRequestArgs(ev.RequestID)
+			if o, err := originOf(ev.Request.URL); err == nil && o == origin {
+				args.SetHeaders(mergeHeaders(ev.Request.Headers, headers))
+			}
+
+			if err = c.Fetch.ContinueRequest(ctx, args); err != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func originOf(s string) (string, error) {
+	u, err := neturl.Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// mergeHeaders returns the headers of a request with extra added, extra
+// replaces headers of the same name.
+func mergeHeaders(request network.Headers, extra map[string]string) []fetch.HeaderEntry {
+	var original map[string]string
+	json.Unmarshal(request, &original)
+
+	var entries []fetch.HeaderEntry
+	for name, value := range original {
+		replaced := false
+		for n := range extra {
+			if strings.EqualFold(n, name) {
+				replaced = true
+			}
+		}
+		if !replaced {
+			entries = append(entries, fetch.HeaderEntry{Name: name, Value: value})
+		}
+	}
+
+	for name, value := range extra {
+		entries = append(entries, fetch.HeaderEntry{Name: name, Value: value})
+	}
+
+	return entries
+}
+
+// load navigates to url and waits until the page is ready: it has fired
+// its load event, the WaitFor element is visible and the delay has passed.
+// The returned collector records the events of the page.
+func load(ctx context.Context, c *cdp.Client, url string, opts Options) (*eventCollector, error) {
+	// Open a DOMContentEventFired client to buffer this event.
+	domContent, err := c.Page.DOMContentEventFired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer domContent.Close()
+
+	lef, err := c.Page.LoadEventFired(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lef.Close()
+
+	// Collect console messages, exceptions and failed requests.
+	events := newEventCollector()
+	if err = events.Start(ctx, c); err != nil {
+		return nil, err
+	}
+
+	// Enable events on the Page domain, it's often preferable to create
+	// event clients before enabling events so that we don't miss any.
+	if err = c.Page.Enable(ctx); err != nil {
+		return nil, err
+	}
+
+	nav, err := c.Page.Navigate(ctx, page.NewNavigateArgs(url))
+	if err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != nil {
+		return nil, fmt.Errorf("%v: %v", url, *nav.ErrorText)
+	}
+
+	// Wait until we have a DOMContentEventFired event.
+	if _, err = domContent.Recv(); err != nil {
+		return nil, err
+	}
+
+	if _, err = lef.Recv(); err != nil {
+		return nil, err
+	}
+
+	if opts.WaitFor != "" {
+		if err = waitVisible(ctx, c, opts.WaitFor); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Delay > 0 {
+		select {
+		case <-time.After(opts.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return events, nil
+}
+
+// waitVisible polls the page until the element matching selector is
+// rendered with a non-empty bounding box.
+func waitVisible(ctx context.Context, c *cdp.Client, selector string) error {
+	expression := `(function(s) {
+		var e = document.querySelector(s);
+		if (!e) return false;
+		var r = e.getBoundingClientRect();
+		return r.width > 0 && r.height > 0 && getComputedStyle(e).visibility !== 'hidden';
+	})(` + strconv.Quote(selector) + `)`
+
+	for {
+		reply, err := c.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs(expression).SetReturnByValue(true))
+		if err != nil {
+			return err
+		}
+		if reply.ExceptionDetails != nil {
+			return errors.New("invalid selector " + selector)
+		}
+		if string(reply.Result.Value) == "true" {
+			return nil
+		}
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %v: %v", selector, ctx.Err())
+		}
+	}
+}
+
+// hide adds a style sheet that hides the elements matching the selectors.
+// They keep their space, so the rest of the page doesn't move.
+func hide(ctx context.Context, c *cdp.Client, selectors []string) error {
+	var css string
+	for _, s := range selectors {
+		css += s + " { visibility: hidden !important; }\n"
+	}
+
+	expression := `(function(css) {
+		var style = document.createElement('style');
+		style.textContent = css;
+		document.head.appendChild(style);
+	})(` + strconv.Quote(css) + `)`
+
+	reply, err := c.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs(expression))
+	if err != nil {
+		return err
+	}
+	if reply.ExceptionDetails != nil {
+		return errors.New("hiding masked elements failed")
+	}
+
+	return nil
+}
+
+func screenshot(ctx context.Context, c *cdp.Client, opts Options, height int) ([]byte, error) {
+	args := page.NewCaptureScreenshotArgs().SetFormat(string(opts.Format)).SetFromSurface(true)
+	if opts.Format != store.PNG {
+		if opts.Quality > 0 {
+			args.SetQuality(opts.Quality)
+		}
+
+		// There is no WebP encoder in Go, so Chrome scales and encodes the
+		// lossy formats itself.
+		if opts.ImageWidth > 0 {
+			args.SetClip(page.Viewport{
+				Width:  float64(opts.Width),
+				Height: float64(height),
+				Scale:  float64(opts.ImageWidth) / (float64(opts.Width) * opts.Scale),
+			})
+		}
+	}
+
+	reply, err := c.Page.CaptureScreenshot(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Format != store.PNG || opts.ImageWidth == 0 {
+		return reply.Data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(reply.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail := resize.Resize(uint(opts.ImageWidth), 0, img, resize.NearestNeighbor)
+
+	buf := new(bytes.Buffer)
+	if err = png.Encode(buf, thumbnail); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/domsnapshot"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"strings"
+)
+
+var snapshotStyles = []string{
+	"display",
+	"visibility",
+	"opacity",
+	"color",
+	"background-color",
+	"font-family",
+	"font-size",
+	"font-weight",
+	"text-decoration",
+}
+
+// captureDom flattens a DOMSnapshot of the page to the rendered elements,
+// each with its own text, bounding box and a subset of its computed styles.
+func captureDom(ctx context.Context, c *cdp.Client) (store.DomSnapshot, error) {
+	var result store.DomSnapshot
+
+	snap, err := c.DOMSnapshot.GetSnapshot(ctx, domsnapshot.NewGetSnapshotArgs(snapshotStyles))
+	if err != nil {
+		return result, err
+	}
+
+	parents := make([]int, len(snap.DOMNodes))
+	for i := range parents {
+		parents[i] = -1
+	}
+	for i, n := range snap.DOMNodes {
+		for _, child := range n.ChildNodeIndexes {
+			parents[child] = i
+		}
+	}
+
+	index := make([]int, len(snap.DOMNodes))
+	for i, n := range snap.DOMNodes {
+		index[i] = -1
+		if n.NodeType != 1 || n.LayoutNodeIndex == nil {
+			continue
+		}
+
+		layout := snap.LayoutTreeNodes[*n.LayoutNodeIndex]
+		node := store.DomNode{
+			Parent: -1,
+			Tag:    strings.ToLower(n.NodeName),
+			Bounds: store.Rect{
+				X:      layout.BoundingBox.X,
+				Y:      layout.BoundingBox.Y,
+				Width:  layout.BoundingBox.Width,
+				Height: layout.BoundingBox.Height,
+			},
+		}
+
+		for _, attr := range n.Attributes {
+			switch attr.Name {
+			case "id":
+				node.Id = attr.Value
+			case "class":
+				node.Class = attr.Value
+			}
+		}
+
+		var text []string
+		for _, child := range n.ChildNodeIndexes {
+			if snap.DOMNodes[child].NodeType == 3 {
+				text = append(text, strings.Fields(snap.DOMNodes[child].NodeValue)...)
+			}
+		}
+		node.Text = strings.Join(text, " ")
+
+		if layout.StyleIndex != nil {
+			node.Styles = make(map[string]string)
+			for _, p := range snap.ComputedStyles[*layout.StyleIndex].Properties {
+				node.Styles[p.Name] = p.Value
+			}
+		}
+
+		path := node.Tag
+		if node.Id != "" {
+			path += "#" + node.Id
+		} else if p := parents[i]; p >= 0 {
+			nth := 1
+			for _, sibling := range snap.DOMNodes[p].ChildNodeIndexes {
+				if sibling == i {
+					break
+				}
+				if snap.DOMNodes[sibling].NodeName == n.NodeName {
+					nth++
+				}
+			}
+			path += fmt.Sprintf(":nth-of-type(%d)", nth)
+		}
+
+		for p := parents[i]; p >= 0; p = parents[p] {
+			if index[p] >= 0 {
+				node.Parent = index[p]
+				path = result.Nodes[index[p]].Path + ">" + path
+				break
+			}
+		}
+		node.Path = path
+
+		index[i] = len(result.Nodes)
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	if len(result.Nodes) > 0 {
+		result.Width = result.Nodes[0].Bounds.Width
+		result.Height = result.Nodes[0].Bounds.Height
+	}
+
+	text, err := c.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs("document.body ? document.body.innerText : ''").SetReturnByValue(true))
+	if err != nil {
+		return result, err
+	}
+
+	if err = json.Unmarshal(text.Result.Value, &result.Text); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
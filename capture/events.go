@@ -0,0 +1,250 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/mafredri/cdp/rpcc"
+	"strings"
+	"sync"
+)
+
+// eventCollector records console messages, uncaught exceptions and failed
+// network requests while a page is being captured.
+type eventCollector struct {
+	lock     sync.Mutex
+	wg       sync.WaitGroup
+	streams  []rpcc.Stream
+	requests map[network.RequestID]string
+	events   []store.PageEvent
+}
+
+func newEventCollector() *eventCollector {
+	return &eventCollector{
+		requests: make(map[network.RequestID]string),
+	}
+}
+
+// Start subscribes to the event streams and enables the Runtime, Log and
+// Network domains. Must be called before navigating.
+func (e *eventCollector) Start(ctx context.Context, c *cdp.Client) error {
+	console, err := c.Runtime.ConsoleAPICalled(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, console)
+
+	exception, err := c.Runtime.ExceptionThrown(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, exception)
+
+	entry, err := c.Log.EntryAdded(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, entry)
+
+	request, err := c.Network.RequestWillBeSent(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, request)
+
+	response, err := c.Network.ResponseReceived(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, response)
+
+	failed, err := c.Network.LoadingFailed(ctx)
+	if err != nil {
+		return err
+	}
+	e.streams = append(e.streams, failed)
+
+	e.receive(func() error {
+		ev, err := console.Recv()
+		if err != nil {
+			return err
+		}
+
+		var args []string
+		for _, arg := range ev.Args {
+			args = append(args, remoteObjectString(arg))
+		}
+
+		e.add(store.PageEvent{
+			Type:    store.ConsoleEvent,
+			Level:   string(ev.Type),
+			Message: strings.Join(args, " "),
+		})
+		return nil
+	})
+
+	e.receive(func() error {
+		ev, err := exception.Recv()
+		if err != nil {
+			return err
+		}
+
+		message := ev.ExceptionDetails.Text
+		if ev.ExceptionDetails.Exception != nil {
+			message = message + " " + remoteObjectString(*ev.ExceptionDetails.Exception)
+		}
+
+		source := ""
+		if ev.ExceptionDetails.URL != nil {
+			source = *ev.ExceptionDetails.URL
+		}
+
+		e.add(store.PageEvent{
+			Type:    store.ExceptionEvent,
+			Level:   "error",
+			Message: message,
+			Source:  source,
+		})
+		return nil
+	})
+
+	e.receive(func() error {
+		ev, err := entry.Recv()
+		if err != nil {
+			return err
+		}
+
+		source := ""
+		if ev.Entry.URL != nil {
+			source = *ev.Entry.URL
+		}
+
+		e.add(store.PageEvent{
+			Type:    store.ConsoleEvent,
+			Level:   string(ev.Entry.Level),
+			Message: ev.Entry.Text,
+			Source:  source,
+		})
+		return nil
+	})
+
+	e.receive(func() error {
+		ev, err := request.Recv()
+		if err != nil {
+			return err
+		}
+
+		e.lock.Lock()
+		e.requests[ev.RequestID] = ev.Request.URL
+		e.lock.Unlock()
+		return nil
+	})
+
+	e.receive(func() error {
+		ev, err := response.Recv()
+		if err != nil {
+			return err
+		}
+
+		if ev.Response.Status >= 400 {
+			e.add(store.PageEvent{
+				Type:    store.NetworkEvent,
+				Level:   "error",
+				Message: fmt.Sprintf("%d %s", ev.Response.Status, ev.Response.StatusText),
+				Source:  ev.Response.URL,
+				Status:  ev.Response.Status,
+			})
+		}
+		return nil
+	})
+
+	e.receive(func() error {
+		ev, err := failed.Recv()
+		if err != nil {
+			return err
+		}
+
+		if ev.Canceled != nil && *ev.Canceled {
+			return nil
+		}
+
+		message := ev.ErrorText
+		if ev.BlockedReason != "" {
+			message = message + " (blocked: " + string(ev.BlockedReason) + ")"
+		}
+
+		e.lock.Lock()
+		source := e.requests[ev.RequestID]
+		e.lock.Unlock()
+
+		e.add(store.PageEvent{
+			Type:    store.NetworkEvent,
+			Level:   "error",
+			Message: message,
+			Source:  source,
+		})
+		return nil
+	})
+
+	if err = c.Runtime.Enable(ctx); err != nil {
+		return err
+	}
+
+	if err = c.Log.Enable(ctx); err != nil {
+		return err
+	}
+
+	return c.Network.Enable(ctx, network.NewEnableArgs())
+}
+
+// Stop closes the event streams and returns everything recorded so far.
+func (e *eventCollector) Stop() []store.PageEvent {
+	for _, s := range e.streams {
+		s.Close()
+	}
+	e.wg.Wait()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.events
+}
+
+func (e *eventCollector) receive(recv func() error) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		for {
+			if err := recv(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (e *eventCollector) add(ev store.PageEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.events = append(e.events, ev)
+}
+
+func remoteObjectString(o runtime.RemoteObject) string {
+	if len(o.Value) > 0 {
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+		return string(o.Value)
+	}
+
+	if o.Description != nil {
+		return *o.Description
+	}
+
+	return string(o.Type)
+}
@@ -0,0 +1,500 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jvdanker/mug/batch"
+	"github.com/jvdanker/mug/capture"
+	"github.com/jvdanker/mug/imgdiff"
+	"github.com/jvdanker/mug/report"
+	"github.com/jvdanker/mug/store"
+	"gopkg.in/yaml.v2"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config lists the urls to check and how to capture and compare them.
+type Config struct {
+	// Baselines is the directory with the baseline images, relative to the
+	// config file. It is meant to be committed.
+	Baselines string `yaml:"baselines"`
+	// Output is the directory that current and diff images of failed checks
+	// are written to, relative to the config file.
+	Output string `yaml:"output"`
+	// BaseUrl is what urls that are paths are resolved against.
+	BaseUrl string `yaml:"baseUrl"`
+	// Override replaces the base url of every url, paths and absolute urls
+	// alike, to check the baselines against another deployment.
+	Override    string  `yaml:"-"`
+	Parallelism int     `yaml:"parallelism"`
+	Metric      string  `yaml:"metric"`
+	Threshold   float64 `yaml:"threshold"`
+	Capture     Capture `yaml:"capture"`
+	Urls        []Url   `yaml:"urls"`
+}
+
+type Capture struct {
+	Width     int           `yaml:"width"`
+	Height    int           `yaml:"height"`
+	Scale     float64       `yaml:"scale"`
+	UserAgent string        `yaml:"userAgent"`
+	Timeout   time.Duration `yaml:"timeout"`
+	WaitFor   string        `yaml:"waitFor"`
+	Delay     time.Duration `yaml:"delay"`
+	Headless  *bool         `yaml:"headless"`
+	Chrome    string        `yaml:"chrome"`
+	Flags     []string      `yaml:"flags"`
+}
+
+// Url is a url to check. Its settings override those of the config.
+type Url struct {
+	Url       string        `yaml:"url"`
+	Name      string        `yaml:"name"`
+	Metric    string        `yaml:"metric"`
+	Threshold *float64      `yaml:"threshold"`
+	WaitFor   string        `yaml:"waitFor"`
+	Delay     time.Duration `yaml:"delay"`
+}
+
+// UnmarshalYAML accepts a plain url as well as an object.
+func (u *Url) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&u.Url); err == nil {
+		return nil
+	}
+
+	type url Url
+	return unmarshal((*url)(u))
+}
+
+const (
+	DefaultBaselines = "baselines"
+	DefaultOutput    = "mug-results"
+	DefaultMetric    = "antialias"
+)
+
+// LoadConfig reads a YAML config. Relative directories are resolved against
+// the directory of the config file.
+func LoadConfig(filename string) (Config, error) {
+	config := Config{
+		Baselines:   DefaultBaselines,
+		Output:      DefaultOutput,
+		Parallelism: 2,
+		Metric:      DefaultMetric,
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+
+	if err = yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("%v: %v", filename, err)
+	}
+
+	if len(config.Urls) == 0 {
+		return config, fmt.Errorf("%v: no urls", filename)
+	}
+
+	if config.BaseUrl != "" {
+		if err = store.ValidBaseUrl(config.BaseUrl); err != nil {
+			return config, fmt.Errorf("%v: %v", filename, err)
+		}
+	}
+
+	for i, u := range config.Urls {
+		if u.Url == "" {
+			return config, fmt.Errorf("%v: url %d has no url", filename, i+1)
+		}
+
+		metric := u.Metric
+		if metric == "" {
+			metric = config.Metric
+		}
+		if _, err = imgdiff.NewMetric(metric, imgdiff.MetricOptions{}); err != nil {
+			return config, fmt.Errorf("%v: %v", filename, err)
+		}
+	}
+
+	dir := filepath.Dir(filename)
+	if !filepath.IsAbs(config.Baselines) {
+		config.Baselines = filepath.Join(dir, config.Baselines)
+	}
+	if !filepath.IsAbs(config.Output) {
+		config.Output = filepath.Join(dir, config.Output)
+	}
+
+	return config, nil
+}
+
+// Resolve returns the address to capture u at.
+func (c Config) Resolve(u Url) (string, error) {
+	p, err := url.Parse(u.Url)
+	if err != nil {
+		return "", err
+	}
+
+	base := c.BaseUrl
+	if c.Override != "" {
+		if err = store.ValidBaseUrl(c.Override); err != nil {
+			return "", err
+		}
+		base = c.Override
+
+		// Keep only the path of absolute urls.
+		if p.IsAbs() {
+			p, _ = url.Parse(p.RequestURI())
+		}
+	}
+
+	if p.IsAbs() {
+		return p.String(), nil
+	}
+	if base == "" {
+		return "", fmt.Errorf("%v is a path, set baseUrl or --base-url", u.Url)
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	return b.ResolveReference(p).String(), nil
+}
+
+// BrowserOptions returns the options to start Chrome with.
+func (c Config) BrowserOptions() capture.BrowserOptions {
+	opts := capture.DefaultBrowserOptions
+	opts.Width, opts.Height = c.CaptureOptions().Width, c.CaptureOptions().Height
+	opts.Path = c.Capture.Chrome
+	opts.Flags = c.Capture.Flags
+	if c.Capture.Headless != nil {
+		opts.Headless = *c.Capture.Headless
+	}
+
+	return opts
+}
+
+// CaptureOptions returns the options that all urls are captured with.
+// Baselines are always stored as PNG.
+func (c Config) CaptureOptions() capture.Options {
+	opts := capture.DefaultOptions
+	if c.Capture.Width > 0 {
+		opts.Width = c.Capture.Width
+	}
+	if c.Capture.Height > 0 {
+		opts.Height = c.Capture.Height
+	}
+	if c.Capture.Scale > 0 {
+		opts.Scale = c.Capture.Scale
+	}
+	if c.Capture.Timeout > 0 {
+		opts.Timeout = c.Capture.Timeout
+	}
+	opts.UserAgent = c.Capture.UserAgent
+	opts.WaitFor = c.Capture.WaitFor
+	opts.Delay = c.Capture.Delay
+	opts.Format = store.PNG
+
+	return opts
+}
+
+// Result is the outcome of checking one url.
+type Result struct {
+	Url  Url
+	Name string
+	// Address is where the url was captured, after resolving it against the
+	// base url.
+	Address string
+	// Status is FAIL when the capture differs from the baseline by more than
+	// the threshold, or when it could not be captured or compared.
+	Status store.StatusType
+	Score  imgdiff.Score
+	// Message describes the result, e.g. why it failed.
+	Message string
+	// Baseline is the path of the baseline image. Current and Diff are only
+	// set for failed checks.
+	Baseline string
+	Current  string
+	Diff     string
+	Updated  bool
+	Duration time.Duration
+}
+
+// Run captures every url and compares it with its baseline. With update set
+// the baselines are replaced by the new captures instead.
+func Run(config Config, capturer capture.Capturer, update bool) ([]Result, error) {
+	// Resolve the urls up front, so a missing base url fails the run
+	// instead of every url.
+	for _, u := range config.Urls {
+		if _, err := config.Resolve(u); err != nil {
+			return nil, err
+		}
+	}
+
+	// Names follow the urls as configured, so that the baselines stay the
+	// same whatever deployment is checked.
+	entries := make([]batch.Entry, len(config.Urls))
+	for i, u := range config.Urls {
+		entries[i] = batch.Entry{Url: u.Url, Name: u.Name}
+	}
+
+	filenames, err := batch.Filenames(batch.DefaultTemplate, entries, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(config.Baselines, 0755); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(entries))
+	batch.Run(entries, filenames, config.Parallelism, func(i int, e batch.Entry, filename string) error {
+		results[i] = checkUrl(config, capturer, config.Urls[i], filename, update)
+		return nil
+	})
+
+	return results, nil
+}
+
+func checkUrl(config Config, capturer capture.Capturer, u Url, filename string, update bool) Result {
+	start := time.Now()
+	name := filename[:len(filename)-len(filepath.Ext(filename))]
+
+	address, _ := config.Resolve(u)
+
+	result := Result{
+		Url:      u,
+		Name:     name,
+		Address:  address,
+		Status:   store.FAIL,
+		Baseline: filepath.Join(config.Baselines, filename),
+	}
+
+	// Remove the images of an earlier failure.
+	os.Remove(filepath.Join(config.Output, name+".current.png"))
+	os.Remove(filepath.Join(config.Output, name+".diff.png"))
+
+	opts := config.CaptureOptions()
+	if u.WaitFor != "" {
+		opts.WaitFor = u.WaitFor
+	}
+	if u.Delay > 0 {
+		opts.Delay = u.Delay
+	}
+
+	captured, err := capturer.Capture(context.Background(), address, opts)
+	if err != nil {
+		result.Message = fmt.Sprintf("capture failed: %v", err)
+		return finish(&result, start)
+	}
+
+	if update {
+		if err = ioutil.WriteFile(result.Baseline, captured.Image, 0644); err != nil {
+			result.Message = err.Error()
+			return finish(&result, start)
+		}
+
+		result.Status = store.SUCCESS
+		result.Updated = true
+		result.Message = "baseline updated"
+		return finish(&result, start)
+	}
+
+	current, err := png.Decode(bytes.NewReader(captured.Image))
+	if err != nil {
+		result.Message = err.Error()
+		return finish(&result, start)
+	}
+
+	baseline, err := loadPNG(result.Baseline)
+	if os.IsNotExist(err) {
+		result.Message = "no baseline, run with --update to create it"
+		writeFailure(config, &result, nil, current)
+		return finish(&result, start)
+	}
+	if err != nil {
+		result.Message = err.Error()
+		return finish(&result, start)
+	}
+
+	metric := u.Metric
+	if metric == "" {
+		metric = config.Metric
+	}
+	threshold := config.Threshold
+	if u.Threshold != nil {
+		threshold = *u.Threshold
+	}
+
+	m, err := imgdiff.NewMetric(metric, imgdiff.MetricOptions{})
+	if err != nil {
+		result.Message = err.Error()
+		return finish(&result, start)
+	}
+
+	result.Score, err = imgdiff.Evaluate(m, baseline, current, threshold)
+	if err != nil {
+		result.Message = err.Error()
+		return finish(&result, start)
+	}
+
+	result.Message = fmt.Sprintf("%s: %f (threshold %f)", result.Score.Metric, result.Score.Value, threshold)
+	if result.Score.Pass {
+		result.Status = store.SUCCESS
+	} else {
+		writeFailure(config, &result, baseline, current)
+	}
+
+	return finish(&result, start)
+}
+
+func finish(result *Result, start time.Time) Result {
+	result.Duration = time.Since(start)
+	return *result
+}
+
+// writeFailure saves the current capture, and a diff image when there is a
+// baseline, to the output directory.
+func writeFailure(config Config, result *Result, baseline, current image.Image) {
+	if err := os.MkdirAll(config.Output, 0755); err != nil {
+		result.Message += "; " + err.Error()
+		return
+	}
+
+	result.Current = filepath.Join(config.Output, result.Name+".current.png")
+	if err := writePNG(result.Current, current); err != nil {
+		result.Message += "; " + err.Error()
+	}
+
+	if baseline == nil {
+		return
+	}
+
+	diff := imgdiff.Highlight(baseline, current, 0)
+	imgdiff.Outline(diff, imgdiff.Regions(baseline, current, imgdiff.DefaultRegionOptions))
+
+	result.Diff = filepath.Join(config.Output, result.Name+".diff.png")
+	if err := writePNG(result.Diff, diff); err != nil {
+		result.Message += "; " + err.Error()
+	}
+}
+
+// Failed returns the results that did not pass.
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Status == store.FAIL {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// Cases turns the results into the test cases of a report. The current and
+// diff images of failed checks are attached.
+func Cases(results []Result) []report.Case {
+	cases := make([]report.Case, len(results))
+	for i, r := range results {
+		cases[i] = report.Case{
+			Name:     r.Url.Url,
+			Status:   r.Status,
+			Message:  r.Message,
+			Duration: r.Duration,
+		}
+
+		for _, a := range []string{r.Diff, r.Current} {
+			if a != "" {
+				cases[i].Attachments = append(cases[i].Attachments, a)
+			}
+		}
+	}
+
+	return cases
+}
+
+// Pages turns the results into the pages of a HTML report. The baseline is
+// the reference image; failed checks also show their current capture and
+// diff image.
+func Pages(results []Result) ([]report.Page, error) {
+	cases := Cases(results)
+
+	pages := make([]report.Page, len(results))
+	for i, r := range results {
+		pages[i] = report.Page{
+			Case: cases[i],
+			Url:  r.Address,
+			Metrics: []report.Metric{
+				{Name: "Name", Value: r.Name},
+				{Name: "Duration", Value: r.Duration.Round(time.Millisecond).String()},
+			},
+		}
+		if r.Score.Metric != "" {
+			pages[i].Metrics = append(pages[i].Metrics,
+				report.Metric{Name: "Metric", Value: r.Score.Metric},
+				report.Metric{Name: "Score", Value: fmt.Sprintf("%f", r.Score.Value)},
+				report.Metric{Name: "Threshold", Value: fmt.Sprintf("%f", r.Score.Threshold)},
+			)
+		}
+
+		var err error
+		if pages[i].Reference, err = readImage(r.Baseline); err != nil {
+			return nil, err
+		}
+		if pages[i].Current, err = readImage(r.Current); err != nil {
+			return nil, err
+		}
+		if pages[i].Overlay, err = readImage(r.Diff); err != nil {
+			return nil, err
+		}
+	}
+
+	return pages, nil
+}
+
+// readImage reads a PNG for a report, a missing file is left out.
+func readImage(filename string) (*report.Image, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &report.Image{Data: data, Format: store.PNG}, nil
+}
+
+func loadPNG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return png.Decode(f)
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
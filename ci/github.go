@@ -0,0 +1,76 @@
+package ci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultGitHubApi is the api of github.com. GitHub Enterprise and
+// compatible services have their own.
+const DefaultGitHubApi = "https://api.github.com"
+
+// GitHub posts statuses with the commit statuses api of GitHub.
+type GitHub struct {
+	ApiUrl string
+	// Repository is "owner/name".
+	Repository string
+	Token      string
+}
+
+func NewGitHub(apiUrl, repository, token string) (GitHub, error) {
+	if apiUrl == "" {
+		apiUrl = DefaultGitHubApi
+	}
+
+	parts := strings.Split(repository, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return GitHub{}, errors.New("repository must be owner/name")
+	}
+
+	return GitHub{
+		ApiUrl:     strings.TrimRight(apiUrl, "/"),
+		Repository: repository,
+		Token:      token,
+	}, nil
+}
+
+type githubStatus struct {
+	State       string `json:"state"`
+	TargetUrl   string `json:"target_url,omitempty"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+func (g GitHub) Post(s Status) error {
+	if s.Commit == "" {
+		return errors.New("missing commit")
+	}
+
+	// GitHub refuses longer descriptions.
+	description := s.Description
+	if len(description) > 140 {
+		description = description[:137] + "..."
+	}
+
+	body, err := json.Marshal(githubStatus{
+		State:       s.State,
+		TargetUrl:   s.TargetUrl,
+		Description: description,
+		Context:     s.Context,
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Accept": "application/vnd.github+json",
+	}
+	if g.Token != "" {
+		headers["Authorization"] = "Bearer " + g.Token
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/statuses/%s", g.ApiUrl, g.Repository, s.Commit)
+	return send("POST", url, headers, body)
+}
@@ -0,0 +1,77 @@
+package ci
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGitHubPost(t *testing.T) {
+	srv, requests := stub(t, http.StatusCreated)
+
+	g, err := NewGitHub(srv.URL+"/api/v3/", "owner/site", "ghp_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testStatus()
+	s.Description = strings.Repeat("x", 150)
+	if err = g.Post(s); err != nil {
+		t.Fatal(err)
+	}
+
+	r := (*requests)[0]
+	if r.method != "POST" || r.path != "/api/v3/repos/owner/site/statuses/abc123" {
+		t.Errorf("request %v %v", r.method, r.path)
+	}
+	if r.header.Get("Authorization") != "Bearer ghp_token" || r.header.Get("Accept") != "application/vnd.github+json" {
+		t.Errorf("headers %v", r.header)
+	}
+
+	var body githubStatus
+	if err = json.Unmarshal([]byte(r.body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Description) != 140 || !strings.HasSuffix(body.Description, "...") {
+		t.Errorf("description of %d characters: %q", len(body.Description), body.Description)
+	}
+	if body.State != Failure || body.Context != DefaultContext || body.TargetUrl != "https://mug.example/run/7" {
+		t.Errorf("body %+v", body)
+	}
+}
+
+func TestGitHubShortDescription(t *testing.T) {
+	srv, requests := stub(t, http.StatusCreated)
+
+	g, _ := NewGitHub(srv.URL, "owner/site", "")
+	s := testStatus()
+	s.Description = strings.Repeat("x", 140)
+	if err := g.Post(s); err != nil {
+		t.Fatal(err)
+	}
+
+	r := (*requests)[0]
+	if _, ok := r.header["Authorization"]; ok {
+		t.Error("authorization without a token")
+	}
+	if !strings.Contains(r.body, `"description":"`+s.Description+`"`) {
+		t.Errorf("body %s", r.body)
+	}
+}
+
+func TestGitHubErrors(t *testing.T) {
+	for _, repo := range []string{"", "site", "owner/", "/site", "a/b/c"} {
+		if _, err := NewGitHub("", repo, ""); err == nil {
+			t.Errorf("repository %q: no error", repo)
+		}
+	}
+
+	g, _ := NewGitHub("", "owner/site", "")
+	if g.ApiUrl != DefaultGitHubApi {
+		t.Errorf("api %v", g.ApiUrl)
+	}
+	if err := g.Post(Status{}); err == nil {
+		t.Error("missing commit: no error")
+	}
+}
@@ -0,0 +1,93 @@
+package ci
+
+import (
+	"encoding/json"
+	"errors"
+	"text/template"
+)
+
+// HTTP posts statuses to any service. The url, headers and body are
+// templates of the Status, e.g.
+//
+//	https://ci.example/api/commits/{{.Commit}}/status
+//
+// Without a body template the status is sent as JSON. json quotes a value
+// in a body template.
+type HTTP struct {
+	Method  string
+	Url     *template.Template
+	Headers map[string]*template.Template
+	Body    *template.Template
+}
+
+func NewHTTP(url, method string, headers map[string]string, body string) (HTTP, error) {
+	if url == "" {
+		return HTTP{}, errors.New("missing url")
+	}
+	if method == "" {
+		method = "POST"
+	}
+
+	h := HTTP{
+		Method:  method,
+		Headers: make(map[string]*template.Template),
+	}
+
+	var err error
+	if h.Url, err = Template("url", url); err != nil {
+		return HTTP{}, err
+	}
+
+	for k, v := range headers {
+		if h.Headers[k], err = Template(k, v); err != nil {
+			return HTTP{}, err
+		}
+	}
+
+	if body != "" {
+		if h.Body, err = Template("body", body); err != nil {
+			return HTTP{}, err
+		}
+	}
+
+	return h, nil
+}
+
+// Request renders the url, headers and body for s.
+func (h HTTP) Request(s Status) (string, map[string]string, []byte, error) {
+	url, err := execute(h.Url, s)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	headers := make(map[string]string)
+	for k, t := range h.Headers {
+		if headers[k], err = execute(t, s); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	if h.Body == nil {
+		body, err := json.Marshal(s)
+		return url, headers, body, err
+	}
+
+	body, err := execute(h.Body, s)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if !json.Valid([]byte(body)) {
+		return "", nil, nil, errors.New("body template does not render JSON")
+	}
+
+	return url, headers, []byte(body), nil
+}
+
+func (h HTTP) Post(s Status) error {
+	url, headers, body, err := h.Request(s)
+	if err != nil {
+		return err
+	}
+
+	return send(h.Method, url, headers, body)
+}
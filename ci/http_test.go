@@ -0,0 +1,118 @@
+package ci
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jvdanker/mug/store"
+)
+
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+// stub records the requests it gets and answers them with code. It
+// replaces Client until it is closed.
+func stub(t *testing.T, code int) (*httptest.Server, *[]request) {
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.Header, string(body)})
+		w.WriteHeader(code)
+	}))
+
+	client := Client
+	Client = srv.Client()
+	t.Cleanup(func() {
+		srv.Close()
+		Client = client
+	})
+
+	return srv, &requests
+}
+
+func testStatus() Status {
+	now := time.Now()
+	run := store.Run{Id: 7, Commit: "abc123", Branch: "main", State: store.RunFinished, Status: store.FAIL, Total: 3, Failed: 1, Created: now, Finished: &now}
+	return FromRun(run, "", "https://mug.example/run/7")
+}
+
+func TestHTTPPost(t *testing.T) {
+	srv, requests := stub(t, http.StatusCreated)
+
+	h, err := NewHTTP(
+		srv.URL+"/commits/{{.Commit}}/checks/{{.Context}}",
+		"PUT",
+		map[string]string{"Authorization": "Bearer abc", "X-Run": "{{.Run.Id}}"},
+		`{"state": {{json .State}}, "summary": {{json .Description}}, "url": {{json .TargetUrl}}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = h.Post(testStatus()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("%d requests", len(*requests))
+	}
+	r := (*requests)[0]
+	if r.method != "PUT" || r.path != "/commits/abc123/checks/mug" {
+		t.Errorf("request %v %v", r.method, r.path)
+	}
+	if r.header.Get("Authorization") != "Bearer abc" || r.header.Get("X-Run") != "7" || r.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers %v", r.header)
+	}
+	if want := `{"state": "failure", "summary": "1 of 3 URLs failed", "url": "https://mug.example/run/7"}`; r.body != want {
+		t.Errorf("body %s, want %s", r.body, want)
+	}
+}
+
+func TestHTTPDefaults(t *testing.T) {
+	srv, requests := stub(t, http.StatusOK)
+
+	h, err := NewHTTP(srv.URL+"/status", "", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Post(testStatus()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := (*requests)[0]
+	if r.method != "POST" {
+		t.Errorf("method %v", r.method)
+	}
+	if want := `"state":"failure"`; !strings.Contains(r.body, want) {
+		t.Errorf("body %s doesn't have %s", r.body, want)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	srv, _ := stub(t, http.StatusUnauthorized)
+
+	if _, err := NewHTTP("", "", nil, ""); err == nil {
+		t.Error("missing url: no error")
+	}
+	if _, err := NewHTTP(srv.URL+"/{{.Commit", "", nil, ""); err == nil {
+		t.Error("invalid template: no error")
+	}
+
+	h, _ := NewHTTP(srv.URL, "", nil, "state: {{.State}}")
+	if err := h.Post(testStatus()); err == nil {
+		t.Error("body that isn't JSON: no error")
+	}
+
+	h, _ = NewHTTP(srv.URL, "", nil, "")
+	if err := h.Post(testStatus()); err == nil {
+		t.Error("401: no error")
+	}
+}
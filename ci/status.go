@@ -0,0 +1,140 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// The states of a commit status, as GitHub names them.
+const (
+	Pending = "pending"
+	Success = "success"
+	Failure = "failure"
+	Error   = "error"
+)
+
+// Status is the outcome of a run as a commit status. TargetUrl links to
+// the report of the run.
+type Status struct {
+	Commit      string    `json:"commit"`
+	Branch      string    `json:"branch"`
+	State       string    `json:"state"`
+	Description string    `json:"description"`
+	Context     string    `json:"context"`
+	TargetUrl   string    `json:"targetUrl"`
+	Run         store.Run `json:"run"`
+}
+
+// Provider posts commit statuses to a CI or code hosting service.
+type Provider interface {
+	Post(s Status) error
+}
+
+// DefaultContext names the status when the configuration doesn't.
+const DefaultContext = "mug"
+
+// FromRun returns the status of run: pending while it runs, failure when
+// one of its urls failed and success otherwise.
+func FromRun(run store.Run, context, targetUrl string) Status {
+	if context == "" {
+		context = DefaultContext
+	}
+
+	s := Status{
+		Commit:    run.Commit,
+		Branch:    run.Branch,
+		Context:   context,
+		TargetUrl: targetUrl,
+		Run:       run,
+	}
+
+	switch {
+	case run.State != store.RunFinished:
+		s.State = Pending
+		s.Description = "Scanning " + urls(run.Total)
+	case run.Status == store.FAIL:
+		s.State = Failure
+		s.Description = fmt.Sprintf("%d of %s failed", run.Failed, urls(run.Total))
+	default:
+		s.State = Success
+		s.Description = urls(run.Total) + " passed"
+		if run.Status == store.WARNING {
+			s.Description += " with warnings"
+		}
+	}
+
+	return s
+}
+
+func urls(n int) string {
+	if n == 1 {
+		return "1 URL"
+	}
+	return fmt.Sprintf("%d URLs", n)
+}
+
+// New returns the provider that c configures.
+func New(c store.CommitStatus) (Provider, error) {
+	switch c.Type {
+	case "http":
+		return NewHTTP(c.Url, c.Method, c.Headers, c.Body)
+	case "github":
+		return NewGitHub(c.ApiUrl, c.Repository, c.Token)
+	default:
+		return nil, fmt.Errorf("unknown commit status provider %q", c.Type)
+	}
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Template parses a template of a status.
+func Template(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+func execute(t *template.Template, s Status) (string, error) {
+	buf := new(bytes.Buffer)
+	err := t.Execute(buf, s)
+	return buf.String(), err
+}
+
+// Client sends the statuses of every provider.
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+func send(method, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mug")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%v %v: %v %s", method, url, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package discover
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CrawlOptions limit a crawl.
+type CrawlOptions struct {
+	// Depth is the number of links to follow from the start page, 0 only
+	// returns the start page.
+	Depth int `json:"depth"`
+	// Limit is the largest number of pages to fetch, 0 means no limit.
+	Limit int `json:"limit"`
+	// Include are regular expressions, when set only urls matching one of
+	// them are returned. Pages that don't match are still followed.
+	Include []string `json:"include"`
+	// Exclude are regular expressions for urls that are neither returned nor
+	// followed.
+	Exclude []string `json:"exclude"`
+}
+
+// Crawl follows the links from start to the pages of the same origin and
+// returns the urls of the HTML pages it found, start included.
+func Crawl(client *http.Client, start string, opts CrawlOptions) ([]string, error) {
+	include, err := compile(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compile(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	origin, err := url.Parse(Normalize(start))
+	if err != nil || origin.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", start)
+	}
+
+	type page struct {
+		url   string
+		depth int
+	}
+
+	var (
+		urls    []string
+		fetched int
+		seen    = map[string]bool{origin.String(): true}
+		queue   = []page{{origin.String(), 0}}
+	)
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if opts.Limit > 0 && fetched == opts.Limit {
+			break
+		}
+		fetched++
+
+		links, ok, err := fetchPage(client, p.url)
+		if err != nil && p.depth == 0 {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if len(include) == 0 || matches(include, p.url) {
+			urls = append(urls, p.url)
+		}
+
+		if p.depth == opts.Depth {
+			continue
+		}
+
+		base, _ := url.Parse(p.url)
+		for _, link := range links {
+			ref, err := base.Parse(link)
+			if err != nil || !sameOrigin(origin, ref) {
+				continue
+			}
+
+			u := Normalize(ref.String())
+			if seen[u] || matches(exclude, u) {
+				continue
+			}
+			seen[u] = true
+			queue = append(queue, page{u, p.depth + 1})
+		}
+	}
+
+	return urls, nil
+}
+
+// fetchPage returns the links of a page and whether it is a page of the
+// site. Pages that fail to load or are not HTML are skipped, there is
+// nothing worth capturing.
+func fetchPage(client *http.Client, u string) ([]string, bool, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("%v: %v", u, resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" {
+		return nil, false, nil
+	}
+
+	// Redirects to another origin are not part of the site.
+	if resp.Request.URL.Host != "" {
+		start, _ := url.Parse(u)
+		if !sameOrigin(start, resp.Request.URL) {
+			return nil, false, nil
+		}
+	}
+
+	return links(resp.Body), true, nil
+}
+
+// links returns the href of every a and area element.
+func links(r io.Reader) []string {
+	var links []string
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if !hasAttr || (string(name) != "a" && string(name) != "area") {
+				continue
+			}
+
+			for {
+				key, val, more := z.TagAttr()
+				if string(key) == "href" {
+					links = append(links, strings.TrimSpace(string(val)))
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+
+	return res, nil
+}
+
+func matches(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Normalize makes urls that point to the same page equal: the scheme and
+// host are lower case, the fragment is dropped and an empty path becomes /.
+// Urls that can't be parsed or aren't http(s) return "".
+func Normalize(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return ""
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u.String()
+}
+
+// New returns the urls that are not in existing, in their normalized form.
+func New(found []string, existing []string) []string {
+	seen := make(map[string]bool)
+	for _, u := range existing {
+		seen[Normalize(u)] = true
+	}
+
+	var urls []string
+	for _, u := range found {
+		n := Normalize(u)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		urls = append(urls, n)
+	}
+
+	return urls
+}
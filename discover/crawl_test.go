@@ -0,0 +1,166 @@
+package discover
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// site serves pages that link to the paths in links. Paths without links
+// are not found.
+func site(links map[string][]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hrefs, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>")
+		for _, href := range hrefs {
+			fmt.Fprintf(w, `<a href="%v">link</a>`, href)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+}
+
+func TestCrawlDepth(t *testing.T) {
+	srv := site(map[string][]string{
+		"/":    {"/a", "/a#top", "b", "/"},
+		"/a":   {"/a/1"},
+		"/b":   {},
+		"/a/1": {"/a/1/x"},
+	})
+	defer srv.Close()
+
+	for depth, want := range [][]string{
+		{"/"},
+		{"/", "/a", "/b"},
+		{"/", "/a", "/b", "/a/1"},
+	} {
+		urls, err := Crawl(srv.Client(), srv.URL, CrawlOptions{Depth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range want {
+			want[i] = srv.URL + want[i]
+		}
+		if !reflect.DeepEqual(urls, want) {
+			t.Errorf("depth %d: got %v, want %v", depth, urls, want)
+		}
+	}
+}
+
+func TestCrawlLimit(t *testing.T) {
+	srv := site(map[string][]string{
+		"/":  {"/a", "/b", "/c"},
+		"/a": {},
+		"/b": {},
+		"/c": {},
+	})
+	defer srv.Close()
+
+	urls, err := Crawl(srv.Client(), srv.URL+"/", CrawlOptions{Depth: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{srv.URL + "/", srv.URL + "/a"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
+
+func TestCrawlIncludeExclude(t *testing.T) {
+	srv := site(map[string][]string{
+		"/":           {"/blog", "/products", "/admin"},
+		"/blog":       {"/blog/post"},
+		"/blog/post":  {},
+		"/products":   {"/products/1"},
+		"/products/1": {},
+		"/admin":      {"/admin/users"},
+	})
+	defer srv.Close()
+
+	opts := CrawlOptions{
+		Depth:   2,
+		Include: []string{"/products", "/blog/"},
+		Exclude: []string{"/admin"},
+	}
+	urls, err := Crawl(srv.Client(), srv.URL+"/", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pages that aren't included are still followed, excluded ones aren't.
+	want := []string{srv.URL + "/products", srv.URL + "/blog/post", srv.URL + "/products/1"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+
+	if _, err = Crawl(srv.Client(), srv.URL, CrawlOptions{Include: []string{"("}}); err == nil {
+		t.Error("invalid pattern: no error")
+	}
+}
+
+func TestCrawlCrossOrigin(t *testing.T) {
+	other := site(map[string][]string{
+		"/":        {},
+		"/landing": {},
+	})
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/away">away</a><a href="/moved">moved</a><a href="%v/">other</a>`, other.URL)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusMovedPermanently)
+	})
+
+	urls, err := Crawl(srv.Client(), srv.URL, CrawlOptions{Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{srv.URL + "/", srv.URL + "/moved"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
+
+func TestCrawlStartFails(t *testing.T) {
+	srv := site(map[string][]string{})
+	defer srv.Close()
+
+	if _, err := Crawl(srv.Client(), srv.URL, CrawlOptions{}); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestNew(t *testing.T) {
+	found := []string{
+		"HTTP://Example.com",
+		"http://example.com/a#top",
+		"http://example.com/a",
+		"http://example.com/b",
+		"mailto:info@example.com",
+	}
+	existing := []string{"http://example.com/b", "http://EXAMPLE.com/"}
+
+	want := []string{"http://example.com/a"}
+	if urls := New(found, existing); !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
@@ -0,0 +1,120 @@
+package discover
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxSitemaps limits how many sitemaps an index may lead to, including
+// nested indexes.
+const maxSitemaps = 1000
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	Urls     []location `xml:"url"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc string `xml:"loc"`
+}
+
+// Sitemap returns the page urls in a sitemap. Sitemap indexes are followed
+// and gzipped sitemaps are unpacked. limit caps the number of urls, 0 means
+// no limit.
+func Sitemap(client *http.Client, url string, limit int) ([]string, error) {
+	var (
+		urls    []string
+		seen    = make(map[string]bool)
+		queue   = []string{url}
+		fetched = make(map[string]bool)
+	)
+
+	for len(queue) > 0 {
+		sitemap := queue[0]
+		queue = queue[1:]
+
+		if fetched[sitemap] {
+			continue
+		}
+		if len(fetched) == maxSitemaps {
+			return urls, fmt.Errorf("%v: more than %d sitemaps", url, maxSitemaps)
+		}
+		fetched[sitemap] = true
+
+		doc, err := fetchSitemap(client, sitemap)
+		if err != nil {
+			return urls, err
+		}
+
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				queue = append(queue, loc)
+			}
+		}
+
+		for _, u := range doc.Urls {
+			loc := Normalize(strings.TrimSpace(u.Loc))
+			if loc == "" || seen[loc] {
+				continue
+			}
+			seen[loc] = true
+			urls = append(urls, loc)
+
+			if limit > 0 && len(urls) == limit {
+				return urls, nil
+			}
+		}
+	}
+
+	return urls, nil
+}
+
+func fetchSitemap(client *http.Client, url string) (sitemapDocument, error) {
+	var doc sitemapDocument
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return doc, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return doc, fmt.Errorf("%v: %v", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return doc, err
+	}
+
+	// sitemap.xml.gz files are served as they are, not with a gzip
+	// Content-Encoding that the transport would undo.
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return doc, fmt.Errorf("%v: %v", url, err)
+		}
+		if data, err = ioutil.ReadAll(io.LimitReader(r, 50<<20)); err != nil {
+			return doc, fmt.Errorf("%v: %v", url, err)
+		}
+	}
+
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("%v: %v", url, err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return doc, fmt.Errorf("%v: not a sitemap", url)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,90 @@
+package discover
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func urlset(locs ...string) string {
+	s := `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`
+	for _, loc := range locs {
+		s += "<url><loc>" + loc + "</loc></url>"
+	}
+	return s + "</urlset>"
+}
+
+func gzipped(s string) []byte {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestSitemapIndex(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+			<sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+			<sitemap><loc>%[1]s/products.xml.gz</loc></sitemap>
+		</sitemapindex>`, srv.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, urlset(srv.URL, srv.URL+"/about", srv.URL+"/about#team"))
+	})
+	mux.HandleFunc("/products.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(gzipped(urlset(srv.URL+"/products/1", srv.URL+"/about")))
+	})
+
+	urls, err := Sitemap(srv.Client(), srv.URL+"/sitemap.xml", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{srv.URL + "/", srv.URL + "/about", srv.URL + "/products/1"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
+
+func TestSitemapLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, urlset("http://a.test/1", "http://a.test/2", "http://a.test/3"))
+	}))
+	defer srv.Close()
+
+	urls, err := Sitemap(srv.Client(), srv.URL, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"http://a.test/1", "http://a.test/2"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
+
+func TestSitemapErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html></html>")
+	})
+
+	for _, path := range []string{"/html", "/missing"} {
+		if _, err := Sitemap(srv.Client(), srv.URL+path, 0); err == nil {
+			t.Errorf("%v: no error", path)
+		}
+	}
+}
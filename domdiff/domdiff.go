@@ -0,0 +1,182 @@
+package domdiff
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/lcs"
+	"github.com/jvdanker/mug/store"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Tolerance in CSS pixels before a change of position is reported.
+const moveTolerance = 1
+
+// Diff compares two DOM snapshots of the same page and returns the elements
+// that were added, removed or moved and the elements whose text or computed
+// styles changed. Bounds refer to the current snapshot, except for removed
+// elements which use the reference bounds.
+func Diff(reference, current store.DomSnapshot) []store.DomChange {
+	refKeys := keys(reference)
+	curKeys := keys(current)
+
+	var changes []store.DomChange
+
+	matchedRef := make([]bool, len(reference.Nodes))
+	matchedCur := make([]bool, len(current.Nodes))
+
+	for _, m := range lcs.Matches(refKeys, curKeys) {
+		matchedRef[m.I] = true
+		matchedCur[m.J] = true
+
+		before := reference.Nodes[m.I]
+		after := current.Nodes[m.J]
+
+		if before.Text != after.Text {
+			changes = append(changes, store.DomChange{
+				Type:   store.TextChanged,
+				Path:   after.Path,
+				Before: before.Text,
+				After:  after.Text,
+				Bounds: after.Bounds,
+			})
+		}
+
+		if b, a := styleDiff(before.Styles, after.Styles); b != "" || a != "" {
+			changes = append(changes, store.DomChange{
+				Type:   store.StyleChanged,
+				Path:   after.Path,
+				Before: b,
+				After:  a,
+				Bounds: after.Bounds,
+			})
+		}
+
+		bx, by := offset(reference, m.I)
+		ax, ay := offset(current, m.J)
+		if math.Abs(bx-ax) > moveTolerance || math.Abs(by-ay) > moveTolerance {
+			changes = append(changes, store.DomChange{
+				Type:   store.ElementMoved,
+				Path:   after.Path,
+				Before: fmt.Sprintf("%.0f,%.0f", bx, by),
+				After:  fmt.Sprintf("%.0f,%.0f", ax, ay),
+				Bounds: after.Bounds,
+			})
+		}
+	}
+
+	// Elements that were reordered in the document show up as removed in
+	// one place and added in another; pair them up by key and text.
+	removed := make(map[string][]int)
+	for i, n := range reference.Nodes {
+		if !matchedRef[i] {
+			k := fmt.Sprintf("%d\x00%s", refKeys[i], n.Text)
+			removed[k] = append(removed[k], i)
+		}
+	}
+
+	for j, n := range current.Nodes {
+		if matchedCur[j] {
+			continue
+		}
+
+		k := fmt.Sprintf("%d\x00%s", curKeys[j], n.Text)
+		if r := removed[k]; len(r) > 0 {
+			removed[k] = r[1:]
+			matchedRef[r[0]] = true
+			changes = append(changes, store.DomChange{
+				Type:   store.ElementMoved,
+				Path:   n.Path,
+				Before: reference.Nodes[r[0]].Path,
+				After:  n.Path,
+				Bounds: n.Bounds,
+			})
+			continue
+		}
+
+		changes = append(changes, store.DomChange{
+			Type:   store.ElementAdded,
+			Path:   n.Path,
+			After:  n.Text,
+			Bounds: n.Bounds,
+		})
+	}
+
+	for i, n := range reference.Nodes {
+		if !matchedRef[i] {
+			changes = append(changes, store.DomChange{
+				Type:   store.ElementRemoved,
+				Path:   n.Path,
+				Before: n.Text,
+				Bounds: n.Bounds,
+			})
+		}
+	}
+
+	return changes
+}
+
+// keys hashes the selector chain (tag, id and class of every ancestor) of
+// each node. Positions among siblings are left out so that inserting an
+// element does not change the keys of the elements after it.
+func keys(s store.DomSnapshot) []uint64 {
+	selectors := make([]string, len(s.Nodes))
+	result := make([]uint64, len(s.Nodes))
+
+	for i, n := range s.Nodes {
+		selector := n.Tag
+		if n.Id != "" {
+			selector += "#" + n.Id
+		}
+		if n.Class != "" {
+			selector += "." + strings.Join(strings.Fields(n.Class), ".")
+		}
+		if n.Parent >= 0 && n.Parent < i {
+			selector = selectors[n.Parent] + ">" + selector
+		}
+		selectors[i] = selector
+
+		h := fnv.New64a()
+		h.Write([]byte(selector))
+		result[i] = h.Sum64()
+	}
+
+	return result
+}
+
+// offset returns the position of a node relative to its parent, so that only
+// the elements that moved themselves are reported and not all of their
+// descendants.
+func offset(s store.DomSnapshot, i int) (float64, float64) {
+	n := s.Nodes[i]
+	if n.Parent < 0 || n.Parent >= len(s.Nodes) {
+		return n.Bounds.X, n.Bounds.Y
+	}
+
+	p := s.Nodes[n.Parent]
+	return n.Bounds.X - p.Bounds.X, n.Bounds.Y - p.Bounds.Y
+}
+
+func styleDiff(before, after map[string]string) (string, string) {
+	var names []string
+	for name, v := range before {
+		if after[name] != v {
+			names = append(names, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b, a []string
+	for _, name := range names {
+		b = append(b, name+": "+before[name])
+		a = append(a, name+": "+after[name])
+	}
+
+	return strings.Join(b, "; "), strings.Join(a, "; ")
+}
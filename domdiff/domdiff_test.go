@@ -0,0 +1,115 @@
+package domdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jvdanker/mug/store"
+)
+
+// page returns a snapshot of a body with a heading, a paragraph and a
+// footer below each other.
+func page() store.DomSnapshot {
+	return store.DomSnapshot{
+		Width:  800,
+		Height: 300,
+		Nodes: []store.DomNode{
+			{Parent: -1, Path: "body", Tag: "body", Bounds: rect(0, 0, 800, 300)},
+			{Parent: 0, Path: "body>h1", Tag: "h1", Text: "Title", Bounds: rect(0, 0, 800, 50),
+				Styles: map[string]string{"color": "black", "font-size": "32px"}},
+			{Parent: 0, Path: "body>p", Tag: "p", Class: "intro", Text: "Hello", Bounds: rect(0, 50, 800, 100)},
+			{Parent: 0, Path: "body>div#footer", Tag: "div", Id: "footer", Text: "Bye", Bounds: rect(0, 250, 800, 50)},
+		},
+	}
+}
+
+func rect(x, y, width, height float64) store.Rect {
+	return store.Rect{X: x, Y: y, Width: width, Height: height}
+}
+
+func TestDiff(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		change func(s *store.DomSnapshot)
+		want   []store.DomChange
+	}{
+		{"unchanged", func(s *store.DomSnapshot) {}, nil},
+		{"text", func(s *store.DomSnapshot) {
+			s.Nodes[2].Text = "Hi"
+		}, []store.DomChange{
+			{Type: store.TextChanged, Path: "body>p", Before: "Hello", After: "Hi", Bounds: rect(0, 50, 800, 100)},
+		}},
+		{"style", func(s *store.DomSnapshot) {
+			s.Nodes[1].Styles = map[string]string{"color": "red", "font-size": "32px", "font-weight": "bold"}
+		}, []store.DomChange{
+			{Type: store.StyleChanged, Path: "body>h1", Before: "color: black; font-weight: ",
+				After: "color: red; font-weight: bold", Bounds: rect(0, 0, 800, 50)},
+		}},
+		{"moved", func(s *store.DomSnapshot) {
+			s.Nodes[3].Bounds.Y = 200
+		}, []store.DomChange{
+			{Type: store.ElementMoved, Path: "body>div#footer", Before: "0,250", After: "0,200", Bounds: rect(0, 200, 800, 50)},
+		}},
+		{"within tolerance", func(s *store.DomSnapshot) {
+			s.Nodes[3].Bounds.Y = 251
+		}, nil},
+		{"moved with the parent", func(s *store.DomSnapshot) {
+			// Only the body moves, its children stay in place relative to it.
+			for i := range s.Nodes {
+				s.Nodes[i].Bounds.X += 100
+			}
+		}, []store.DomChange{
+			{Type: store.ElementMoved, Path: "body", Before: "0,0", After: "100,0", Bounds: rect(100, 0, 800, 300)},
+		}},
+		{"added", func(s *store.DomSnapshot) {
+			s.Nodes = append(s.Nodes, store.DomNode{Parent: 3, Path: "body>div#footer>a", Tag: "a", Text: "Contact",
+				Bounds: rect(10, 260, 100, 20)})
+		}, []store.DomChange{
+			{Type: store.ElementAdded, Path: "body>div#footer>a", After: "Contact", Bounds: rect(10, 260, 100, 20)},
+		}},
+		{"removed", func(s *store.DomSnapshot) {
+			s.Nodes = append(s.Nodes[:2], s.Nodes[3])
+			s.Nodes[2].Parent = 0
+		}, []store.DomChange{
+			{Type: store.ElementRemoved, Path: "body>p", Before: "Hello", Bounds: rect(0, 50, 800, 100)},
+		}},
+		{"replaced", func(s *store.DomSnapshot) {
+			s.Nodes[2].Class = "summary"
+			s.Nodes[2].Path = "body>p.summary"
+		}, []store.DomChange{
+			{Type: store.ElementAdded, Path: "body>p.summary", After: "Hello", Bounds: rect(0, 50, 800, 100)},
+			{Type: store.ElementRemoved, Path: "body>p", Before: "Hello", Bounds: rect(0, 50, 800, 100)},
+		}},
+	} {
+		current := page()
+		c.change(&current)
+
+		got := Diff(page(), current)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDiffReordered(t *testing.T) {
+	// The heading and the paragraph swap places in the document but keep
+	// their position on the page. One of them is matched in order, the other
+	// one is paired up by key and text and reported as moved.
+	current := page()
+	current.Nodes[1], current.Nodes[2] = current.Nodes[2], current.Nodes[1]
+	current.Nodes[1].Path = "body>p:first-child"
+	current.Nodes[2].Path = "body>h1:nth-child(2)"
+
+	before := map[string]string{
+		"body>p:first-child":   "body>p",
+		"body>h1:nth-child(2)": "body>h1",
+	}
+
+	got := Diff(page(), current)
+	if len(got) != 1 {
+		t.Fatalf("got %+v, want a single change", got)
+	}
+	if got[0].Type != store.ElementMoved || got[0].After != got[0].Path || got[0].Before != before[got[0].Path] {
+		t.Errorf("got %+v, want the element moved from its old path", got[0])
+	}
+}
@@ -23,6 +23,16 @@ func Decorate(h JsonHandler, decorators ...Decorator) http.HandlerFunc {
 			return
 		}
 
+		if raw, ok := data.(store.RawResponse); ok {
+			w.Header().Set("Content-Type", raw.ContentType)
+			if raw.Filename != "" {
+				w.Header().Set("Content-Disposition", "attachment; filename=\""+raw.Filename+"\"")
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(raw.Data)
+			return
+		}
+
 		if data == nil {
 			var s struct{}
 			data = s
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/jvdanker/mug/api"
 	"github.com/jvdanker/mug/store"
+	"github.com/jvdanker/mug/transfer"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -48,18 +49,26 @@ func (h HttpHandlers) HandleShutdown(r *http.Request) (interface{}, error) {
 }
 
 func (h HttpHandlers) HandleListRequests(r *http.Request) (interface{}, error) {
-	l, err := h.a.List()
+	q := r.URL.Query()
+	l, err := h.a.List(api.Filter{
+		Project: q.Get("project"),
+		Group:   q.Get("group"),
+		Tag:     q.Get("tag"),
+	})
 	return l, err
 }
 
 func (h HttpHandlers) HandleScanAllRequests(r *http.Request) (interface{}, error) {
-	var t struct {
-		Type string `json:"type"`
-	}
+	var t api.ScanRequest
+
 	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
 
-	l, err := h.a.ScanAll(t.Type)
-	return l, err
+	t.ServerUrl = baseUrl(r)
+
+	return h.a.ScanAll(t)
 }
 
 func (h HttpHandlers) HandleScanRequests(r *http.Request) (interface{}, error) {
@@ -68,7 +77,7 @@ func (h HttpHandlers) HandleScanRequests(r *http.Request) (interface{}, error) {
 		return nil, err
 	}
 
-	err = h.a.SubmitScanRequest(id)
+	err = h.a.SubmitScanRequest(id, r.URL.Query().Get("baseUrl"))
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +99,20 @@ func (h HttpHandlers) HandleInitRequests(r *http.Request) (interface{}, error) {
 	return nil, nil
 }
 
+func (h HttpHandlers) HandleDiffRequest(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/diff/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.Diff(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
+
 func (h HttpHandlers) HandlePDiffRequest(r *http.Request) (interface{}, error) {
 	id, err := strconv.Atoi(r.URL.Path[len("/pdiff/"):])
 	if err != nil {
@@ -104,6 +127,20 @@ func (h HttpHandlers) HandlePDiffRequest(r *http.Request) (interface{}, error) {
 	return resp, err
 }
 
+func (h HttpHandlers) HandleAlignDiffRequest(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/align/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.AlignDiff(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, err
+}
+
 func (h HttpHandlers) HandleGetReferenceScreenshot(r *http.Request) (interface{}, error) {
 	id, err := strconv.Atoi(r.URL.Path[len("/screenshot/reference/get/"):])
 	if err != nil {
@@ -132,17 +169,142 @@ func (h HttpHandlers) HandleGetScanScreenshot(r *http.Request) (interface{}, err
 	return resp, nil
 }
 
+func (h HttpHandlers) HandleGetOverlayImage(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/screenshot/overlay/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.GetOverlayImage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleReport(r *http.Request) (interface{}, error) {
+	format := r.URL.Path[len("/report/"):]
+
+	resp, err := h.a.Report(format, baseUrl(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleGetEvents(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/url/events/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.GetEvents(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleGetDomChanges(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/url/dom/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.GetDomChanges(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 func (h HttpHandlers) HandleAddUrl(r *http.Request) (interface{}, error) {
-	var t struct {
-		Url string `json:"url"`
+	// Only the settings are read, screenshots and results come from scans.
+	var t transfer.Settings
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.AddUrl(t.ToUrl())
+	if err != nil {
+		return nil, err
 	}
 
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleDiscover(r *http.Request) (interface{}, error) {
+	var t api.DiscoverRequest
+
 	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	resp, err := h.a.Discover(t)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := h.a.AddUrl(t.Url)
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleBulkAdd(r *http.Request) (interface{}, error) {
+	// Only the settings are read, like by HandleAddUrl.
+	var t []transfer.Settings
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	var urls []store.Url
+	for _, s := range t {
+		urls = append(urls, s.ToUrl())
+	}
+
+	return h.a.BulkAdd(urls)
+}
+
+func (h HttpHandlers) HandleBulkUpdate(r *http.Request) (interface{}, error) {
+	var t []transfer.Settings
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	var urls []store.Url
+	for _, s := range t {
+		u := s.ToUrl()
+		u.Id = s.Id
+		urls = append(urls, u)
+	}
+
+	return h.a.BulkUpdate(urls)
+}
+
+func (h HttpHandlers) HandleBulkDelete(r *http.Request) (interface{}, error) {
+	var t struct {
+		Ids []int `json:"ids"`
+	}
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	return h.a.BulkDelete(t.Ids)
+}
+
+func (h HttpHandlers) HandleExport(r *http.Request) (interface{}, error) {
+	resp, err := h.a.Export(r.URL.Path[len("/url/export/"):])
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +312,22 @@ func (h HttpHandlers) HandleAddUrl(r *http.Request) (interface{}, error) {
 	return resp, nil
 }
 
+func (h HttpHandlers) HandleImport(r *http.Request) (interface{}, error) {
+	if r.Method != "POST" {
+		return nil, store.HandlerError{"", http.StatusMethodNotAllowed}
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.URL.Query()
+	dryRun, _ := strconv.ParseBool(q.Get("dryRun"))
+
+	return h.a.Import(r.URL.Path[len("/url/import/"):], transfer.Mode(q.Get("mode")), dryRun, data)
+}
+
 func (h HttpHandlers) HandleDeleteUrl(r *http.Request) (interface{}, error) {
 	if r.Method != "DELETE" {
 		return nil, store.HandlerError{"", http.StatusNotFound}
@@ -168,8 +346,261 @@ func (h HttpHandlers) HandleDeleteUrl(r *http.Request) (interface{}, error) {
 	return nil, nil
 }
 
+func (h HttpHandlers) HandleListGroups(r *http.Request) (interface{}, error) {
+	l, err := h.a.ListGroups()
+	return l, err
+}
+
+func (h HttpHandlers) HandleSaveGroup(r *http.Request) (interface{}, error) {
+	var t store.Group
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.SaveGroup(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleDeleteGroup(r *http.Request) (interface{}, error) {
+	if r.Method != "DELETE" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	_, err := h.a.DeleteGroup(r.URL.Path[len("/group/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (h HttpHandlers) HandleCompare(r *http.Request) (interface{}, error) {
+	var t api.CompareRequest
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	resp, err := h.a.Compare(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleListComparisons(r *http.Request) (interface{}, error) {
+	l, err := h.a.ListComparisons()
+	return l, err
+}
+
+func (h HttpHandlers) HandleGetComparison(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/comparison/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.GetComparison(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleStartRun(r *http.Request) (interface{}, error) {
+	var t api.ScanRequest
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	t.ServerUrl = baseUrl(r)
+
+	resp, err := h.a.StartRun(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleListRuns(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	l, err := h.a.ListRuns(q.Get("branch"), q.Get("commit"))
+	return l, err
+}
+
+func (h HttpHandlers) HandleGetRun(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/run/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.GetRun(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleListProjects(r *http.Request) (interface{}, error) {
+	l, err := h.a.ListProjects()
+	return l, err
+}
+
+func (h HttpHandlers) HandleSaveProject(r *http.Request) (interface{}, error) {
+	var t store.Project
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.SaveProject(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleDeleteProject(r *http.Request) (interface{}, error) {
+	if r.Method != "DELETE" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	_, err := h.a.DeleteProject(r.URL.Path[len("/project/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (h HttpHandlers) HandleListWebhooks(r *http.Request) (interface{}, error) {
+	l, err := h.a.ListWebhooks()
+	return l, err
+}
+
+func (h HttpHandlers) HandleSaveWebhook(r *http.Request) (interface{}, error) {
+	var t store.Webhook
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	resp, err := h.a.SaveWebhook(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleDeleteWebhook(r *http.Request) (interface{}, error) {
+	if r.Method != "DELETE" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	id, err := strconv.Atoi(r.URL.Path[len("/webhook/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.a.DeleteWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (h HttpHandlers) HandleTestWebhook(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/webhook/test/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.a.TestWebhook(id, r.URL.Query().Get("event"))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleListDeliveries(r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(r.URL.Path[len("/webhook/deliveries/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := h.a.ListDeliveries(id)
+	return l, err
+}
+
+func (h HttpHandlers) HandleListCommitStatuses(r *http.Request) (interface{}, error) {
+	l, err := h.a.ListCommitStatuses()
+	return l, err
+}
+
+func (h HttpHandlers) HandleSaveCommitStatus(r *http.Request) (interface{}, error) {
+	var t store.CommitStatus
+
+	err := parseBody(r, &t)
+	if err != nil {
+		return nil, store.HandlerError{err.Error(), http.StatusBadRequest}
+	}
+
+	resp, err := h.a.SaveCommitStatus(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (h HttpHandlers) HandleDeleteCommitStatus(r *http.Request) (interface{}, error) {
+	if r.Method != "DELETE" {
+		return nil, store.HandlerError{"", http.StatusNotFound}
+	}
+
+	id, err := strconv.Atoi(r.URL.Path[len("/status/"):])
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.a.DeleteCommitStatus(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
 // *********************************************************************************
 
+// baseUrl is the url that the client reached the server on.
+func baseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
 func parseBody(r *http.Request, v interface{}) error {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -0,0 +1,201 @@
+package imgdiff
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/lcs"
+	"hash/fnv"
+	"image"
+	"image/color"
+)
+
+type Axis string
+
+const (
+	Vertical   Axis = "vertical"
+	Horizontal Axis = "horizontal"
+)
+
+type Shift struct {
+	Axis   Axis `json:"axis"`
+	At     int  `json:"at"`
+	Offset int  `json:"offset"`
+}
+
+func (s Shift) String() string {
+	if s.Axis == Horizontal {
+		return fmt.Sprintf("content shifted by %d px at x=%d", s.Offset, s.At)
+	}
+	return fmt.Sprintf("content shifted by %d px at y=%d", s.Offset, s.At)
+}
+
+type Alignment struct {
+	Axis    Axis     `json:"axis"`
+	Shifts  []Shift  `json:"shifts"`
+	Regions []Region `json:"regions"`
+	// Pixels is the number of pixels that still differ after aligning.
+	Pixels int `json:"pixels"`
+}
+
+// Align matches the rows (and columns) of a and b so that content that was
+// pushed down or sideways is compared with where it ended up instead of
+// where it used to be. It returns the shifts that were found and the regions
+// of b that still differ after aligning, using whichever axis explains the
+// differences best. Shifts are positioned in a, regions in b.
+func Align(a, b image.Image, opts RegionOptions) Alignment {
+	v := alignRows(a, b, opts)
+	v.Axis = Vertical
+	for i := range v.Shifts {
+		v.Shifts[i].Axis = Vertical
+	}
+	if v.Pixels == 0 {
+		return v
+	}
+
+	h := alignRows(transposed{a}, transposed{b}, opts)
+	if h.Pixels >= v.Pixels {
+		return v
+	}
+
+	h.Axis = Horizontal
+	for i := range h.Shifts {
+		h.Shifts[i].Axis = Horizontal
+	}
+	for i := range h.Regions {
+		h.Regions[i].Bounds = transpose(h.Regions[i].Bounds)
+	}
+
+	return h
+}
+
+func alignRows(a, b image.Image, opts RegionOptions) Alignment {
+	var result Alignment
+
+	ba, bb := a.Bounds(), b.Bounds()
+	w := min(ba.Dx(), bb.Dx())
+
+	ha := rowHashes(a, w, opts.Tolerance)
+	hb := rowHashes(b, w, opts.Tolerance)
+
+	gap := func(i, j, gapA, gapB int) {
+		n := min(gapA, gapB)
+		if n > 0 {
+			r := image.Rect(bb.Min.X, bb.Min.Y+j, bb.Min.X+w, bb.Min.Y+j+n)
+			moved := window{a, r, ba.Min.X - bb.Min.X, (ba.Min.Y + i) - (bb.Min.Y + j)}
+			for _, region := range Regions(moved, window{b, r, 0, 0}, opts) {
+				result.Regions = append(result.Regions, region)
+				result.Pixels += region.Pixels
+			}
+		}
+
+		if gapA != gapB {
+			rows := max(gapA, gapB) - n
+			height := 1
+			if gapB > gapA {
+				height = rows
+			}
+
+			result.Regions = append(result.Regions, Region{
+				Bounds:   image.Rect(bb.Min.X, bb.Min.Y+j+n, bb.Min.X+w, bb.Min.Y+j+n+height),
+				Pixels:   rows * w,
+				Severity: High,
+			})
+			result.Pixels += rows * w
+		}
+	}
+
+	if ba.Dx() != bb.Dx() {
+		r := image.Rect(bb.Min.X+w, bb.Min.Y, bb.Max.X, bb.Max.Y)
+		if ba.Dx() > bb.Dx() {
+			r = image.Rect(bb.Max.X-1, bb.Min.Y, bb.Max.X, bb.Max.Y)
+		}
+
+		pixels := (max(ba.Dx(), bb.Dx()) - w) * bb.Dy()
+		result.Regions = append(result.Regions, Region{
+			Bounds:   r,
+			Pixels:   pixels,
+			Severity: High,
+		})
+		result.Pixels += pixels
+	}
+
+	offset := 0
+	prevI, prevJ := 0, 0
+	for _, m := range append(lcs.Matches(ha, hb), lcs.Match{I: len(ha), J: len(hb)}) {
+		if m.I > prevI || m.J > prevJ {
+			gap(prevI, prevJ, m.I-prevI, m.J-prevJ)
+		}
+
+		if m.I < len(ha) && m.J-m.I != offset {
+			result.Shifts = append(result.Shifts, Shift{
+				At:     ba.Min.Y + m.I,
+				Offset: m.J - m.I - offset,
+			})
+			offset = m.J - m.I
+		}
+
+		prevI, prevJ = m.I+1, m.J+1
+	}
+
+	return result
+}
+
+// rowHashes hashes the first w pixels of every row. Channels are quantized by
+// the tolerance so that rows that are nearly identical usually hash the same.
+func rowHashes(img image.Image, w int, tolerance uint8) []uint64 {
+	b := img.Bounds()
+	q := uint32(tolerance) + 1
+
+	hashes := make([]uint64, b.Dy())
+	rgba := pixelReader(img)
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		buf := make([]byte, 4*w)
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				r, g, bl, a := rgba(b.Min.X+x, y)
+				buf[4*x] = byte((r >> 8) / q)
+				buf[4*x+1] = byte((g >> 8) / q)
+				buf[4*x+2] = byte((bl >> 8) / q)
+				buf[4*x+3] = byte((a >> 8) / q)
+			}
+
+			h := fnv.New64a()
+			h.Write(buf)
+			hashes[y-b.Min.Y] = h.Sum64()
+		}
+	})
+
+	return hashes
+}
+
+// window exposes the part r of an image, reading each pixel at an offset
+// of (dx, dy) in the underlying image.
+type window struct {
+	image.Image
+	r      image.Rectangle
+	dx, dy int
+}
+
+func (w window) Bounds() image.Rectangle {
+	return w.r
+}
+
+func (w window) At(x, y int) color.Color {
+	return w.Image.At(x+w.dx, y+w.dy)
+}
+
+// transposed mirrors an image along its diagonal, turning columns into rows.
+type transposed struct {
+	image.Image
+}
+
+func (t transposed) Bounds() image.Rectangle {
+	return transpose(t.Image.Bounds())
+}
+
+func (t transposed) At(x, y int) color.Color {
+	return t.Image.At(y, x)
+}
+
+func transpose(r image.Rectangle) image.Rectangle {
+	return image.Rect(r.Min.Y, r.Min.X, r.Max.Y, r.Max.X)
+}
@@ -0,0 +1,116 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// line returns a distinct color for every id.
+func line(id int) color.Color {
+	return color.NRGBA{uint8(id), uint8(id / 256 * 64), uint8(255 - id%256), 0xff}
+}
+
+// rows returns an image w pixels wide with a row in the color of each id.
+func rows(w int, ids []int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, len(ids)))
+	for y, id := range ids {
+		block(img, image.Rect(0, y, w, y+1), line(id))
+	}
+	return img
+}
+
+// columns returns an image h pixels high with a column in the color of each
+// id.
+func columns(h int, ids []int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(ids), h))
+	for x, id := range ids {
+		block(img, image.Rect(x, 0, x+1, h), line(id))
+	}
+	return img
+}
+
+// ids returns the ids 0 to n-1 with band inserted at i and the ids i up to
+// i+removed left out.
+func ids(n, i, removed int, band ...int) []int {
+	var result []int
+	for id := 0; id < n; id++ {
+		if id == i {
+			result = append(result, band...)
+		}
+		if id < i || id >= i+removed {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func TestAlign(t *testing.T) {
+	band := []int{1000, 1001, 1002, 1003, 1004}
+
+	changed := rows(20, ids(50, 20, 0, band...))
+	changed.Set(3, 40, color.Black)
+
+	for _, c := range []struct {
+		name    string
+		a, b    image.Image
+		axis    Axis
+		shifts  []Shift
+		regions []image.Rectangle
+		pixels  int
+	}{
+		{"identical", rows(20, ids(50, 0, 0)), rows(20, ids(50, 0, 0)), Vertical, nil, nil, 0},
+		{"rows inserted",
+			rows(20, ids(50, 0, 0)), rows(20, ids(50, 20, 0, band...)), Vertical,
+			[]Shift{{Vertical, 20, 5}},
+			[]image.Rectangle{image.Rect(0, 20, 20, 25)},
+			100},
+		{"rows removed",
+			rows(20, ids(50, 0, 0)), rows(20, ids(50, 20, 5)), Vertical,
+			[]Shift{{Vertical, 25, -5}},
+			[]image.Rectangle{image.Rect(0, 20, 20, 21)},
+			100},
+		{"rows inserted and removed",
+			rows(20, ids(50, 0, 0)), rows(20, append(ids(30, 10, 0, band...), ids(50, 0, 35)...)), Vertical,
+			[]Shift{{Vertical, 10, 5}, {Vertical, 35, -5}},
+			[]image.Rectangle{image.Rect(0, 10, 20, 15), image.Rect(0, 35, 20, 36)},
+			200},
+		{"rows inserted and a pixel changed",
+			rows(20, ids(50, 0, 0)), changed, Vertical,
+			[]Shift{{Vertical, 20, 5}},
+			[]image.Rectangle{image.Rect(0, 20, 20, 25), image.Rect(3, 40, 4, 41)},
+			101},
+		{"columns inserted",
+			columns(20, ids(50, 0, 0)), columns(20, ids(50, 20, 0, band...)), Horizontal,
+			[]Shift{{Horizontal, 20, 5}},
+			[]image.Rectangle{image.Rect(20, 0, 25, 20)},
+			100},
+		{"columns removed",
+			columns(20, ids(50, 0, 0)), columns(20, ids(50, 20, 5)), Horizontal,
+			[]Shift{{Horizontal, 25, -5}},
+			[]image.Rectangle{image.Rect(20, 0, 21, 20)},
+			100},
+	} {
+		r := Align(c.a, c.b, DefaultRegionOptions)
+
+		var regions []image.Rectangle
+		for _, region := range r.Regions {
+			regions = append(regions, region.Bounds)
+		}
+
+		if r.Axis != c.axis || !reflect.DeepEqual(r.Shifts, c.shifts) {
+			t.Errorf("%v: got %v %v, want %v %v", c.name, r.Axis, r.Shifts, c.axis, c.shifts)
+		}
+		if !reflect.DeepEqual(regions, c.regions) || r.Pixels != c.pixels {
+			t.Errorf("%v: got %v and %d pixels, want %v and %d pixels", c.name, regions, r.Pixels, c.regions, c.pixels)
+		}
+
+		// Without aligning, everything after the band differs.
+		if c.pixels > 0 {
+			if p := Compare(c.a, c.b, 0).Pixels; p <= r.Pixels {
+				t.Errorf("%v: %d pixels without aligning, %d with", c.name, p, r.Pixels)
+			}
+		}
+	}
+}
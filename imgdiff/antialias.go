@@ -0,0 +1,86 @@
+package imgdiff
+
+import (
+	"image"
+)
+
+// antialiased reports whether the pixel at (x, y) in img looks like part of
+// an anti-aliased edge: its neighbours get both brighter and darker, and the
+// darkest or brightest neighbour sits in a flat area in both images. This is
+// the approach of "Anti-aliased Pixel and Intensity Slope Detector" by
+// V. Vysniauskas, as used by pixelmatch.
+func antialiased(img, other *image.NRGBA, x, y int, bounds image.Rectangle) bool {
+	x0, y0 := max(x-1, bounds.Min.X), max(y-1, bounds.Min.Y)
+	x1, y1 := min(x+1, bounds.Max.X-1), min(y+1, bounds.Max.Y-1)
+
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+
+	var minDelta, maxDelta float64
+	var minX, minY, maxX, maxY int
+
+	p := pixelAt(img, x, y)
+	for ny := y0; ny <= y1; ny++ {
+		for nx := x0; nx <= x1; nx++ {
+			if nx == x && ny == y {
+				continue
+			}
+
+			delta := yiqDelta(p, pixelAt(img, nx, ny), true)
+			switch {
+			case delta == 0:
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			case delta < minDelta:
+				minDelta = delta
+				minX, minY = nx, ny
+			case delta > maxDelta:
+				maxDelta = delta
+				maxX, maxY = nx, ny
+			}
+		}
+	}
+
+	// Without both a darker and a brighter neighbour it's not an edge.
+	if minDelta == 0 || maxDelta == 0 {
+		return false
+	}
+
+	return (hasManySiblings(img, minX, minY, bounds) && hasManySiblings(other, minX, minY, bounds)) ||
+		(hasManySiblings(img, maxX, maxY, bounds) && hasManySiblings(other, maxX, maxY, bounds))
+}
+
+// hasManySiblings reports whether more than two of the neighbours of the
+// pixel at (x, y) have exactly the same color.
+func hasManySiblings(img *image.NRGBA, x, y int, bounds image.Rectangle) bool {
+	x0, y0 := max(x-1, bounds.Min.X), max(y-1, bounds.Min.Y)
+	x1, y1 := min(x+1, bounds.Max.X-1), min(y+1, bounds.Max.Y-1)
+
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+
+	p := pixelAt(img, x, y)
+	for ny := y0; ny <= y1; ny++ {
+		for nx := x0; nx <= x1; nx++ {
+			if nx == x && ny == y {
+				continue
+			}
+
+			n := pixelAt(img, nx, ny)
+			if p[0] == n[0] && p[1] == n[1] && p[2] == n[2] && p[3] == n[3] {
+				zeroes++
+			}
+			if zeroes > 2 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package imgdiff
+
+import (
+	"fmt"
+	"math"
+)
+
+type ColorSpace string
+
+const (
+	// RGB compares the largest per-channel difference (0-255).
+	RGB ColorSpace = "rgb"
+	// YIQ compares the perceived difference in the YIQ color space, as a
+	// fraction (0-1) of the largest possible difference.
+	YIQ ColorSpace = "yiq"
+	// CIEDE2000 compares the CIE Delta E 2000 color difference, where 1 is
+	// about the smallest difference the eye can see.
+	CIEDE2000 ColorSpace = "ciede2000"
+)
+
+// Largest possible YIQ delta, between black and white.
+const maxYIQDelta = 35215
+
+var DefaultColorDistance = map[ColorSpace]float64{
+	RGB:       0,
+	YIQ:       0.1,
+	CIEDE2000: 2.3,
+}
+
+// pixelsDiffer reports whether two NRGBA pixels differ by more than the
+// allowed distance.
+type pixelsDiffer func(p1, p2 []uint8) bool
+
+func newPixelsDiffer(space ColorSpace, distance float64) (pixelsDiffer, error) {
+	switch space {
+	case RGB, "":
+		tolerance := uint8(math.Min(math.Max(distance, 0), 0xff))
+		return func(p1, p2 []uint8) bool {
+			for c := 0; c < 4; c++ {
+				if absDiff(p1[c], p2[c]) > tolerance {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case YIQ:
+		limit := maxYIQDelta * distance * distance
+		return func(p1, p2 []uint8) bool {
+			return math.Abs(yiqDelta(p1, p2, false)) > limit
+		}, nil
+	case CIEDE2000:
+		return func(p1, p2 []uint8) bool {
+			return deltaE2000(lab(p1), lab(p2)) > distance
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown color space %q", space)
+	}
+}
+
+// blend composites a channel with the given alpha onto white.
+func blend(c uint8, a float64) float64 {
+	return 255 + (float64(c)-255)*a
+}
+
+func blended(p []uint8) (float64, float64, float64) {
+	a := float64(p[3]) / 255
+	return blend(p[0], a), blend(p[1], a), blend(p[2], a)
+}
+
+// yiqDelta returns the squared YIQ distance between two pixels, negative
+// when the first pixel is the brighter one. With brightnessOnly set it
+// returns the difference in luma instead.
+func yiqDelta(p1, p2 []uint8, brightnessOnly bool) float64 {
+	r1, g1, b1 := blended(p1)
+	r2, g2, b2 := blended(p2)
+
+	y1 := r1*0.29889531 + g1*0.58662247 + b1*0.11448223
+	y2 := r2*0.29889531 + g2*0.58662247 + b2*0.11448223
+	y := y1 - y2
+
+	if brightnessOnly {
+		return y
+	}
+
+	i := (r1*0.59597799 - g1*0.27417610 - b1*0.32180189) - (r2*0.59597799 - g2*0.27417610 - b2*0.32180189)
+	q := (r1*0.21147017 - g1*0.52261711 + b1*0.31114694) - (r2*0.21147017 - g2*0.52261711 + b2*0.31114694)
+
+	delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+	if y1 > y2 {
+		return -delta
+	}
+	return delta
+}
+
+type labColor struct {
+	l, a, b float64
+}
+
+// lab converts an sRGB pixel, composited onto white, to CIE L*a*b* (D65).
+func lab(p []uint8) labColor {
+	r, g, b := blended(p)
+
+	linear := func(c float64) float64 {
+		c /= 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	r, g, b = linear(r), linear(g), linear(b)
+
+	x := (r*0.4124564 + g*0.3575761 + b*0.1804375) / 0.95047
+	y := r*0.2126729 + g*0.7151522 + b*0.0721750
+	z := (r*0.0193339 + g*0.1191920 + b*0.9503041) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return labColor{
+		l: 116*fy - 16,
+		a: 500 * (fx - fy),
+		b: 200 * (fy - fz),
+	}
+}
+
+// deltaE2000 implements the CIEDE2000 color difference formula.
+func deltaE2000(c1, c2 labColor) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	deg := func(rad float64) float64 { return rad * 180 / math.Pi }
+
+	cab := (math.Hypot(c1.a, c1.b) + math.Hypot(c2.a, c2.b)) / 2
+	g := 0.5 * (1 - math.Sqrt(math.Pow(cab, 7)/(math.Pow(cab, 7)+math.Pow(25, 7))))
+
+	a1, a2 := (1+g)*c1.a, (1+g)*c2.a
+	cp1, cp2 := math.Hypot(a1, c1.b), math.Hypot(a2, c2.b)
+
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := deg(math.Atan2(b, a))
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+	hp1, hp2 := hue(c1.b, a1), hue(c2.b, a2)
+
+	dl := c2.l - c1.l
+	dc := cp2 - cp1
+
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(rad(dh/2))
+
+	lp := (c1.l + c2.l) / 2
+	cp := (cp1 + cp2) / 2
+
+	hp := hp1 + hp2
+	if cp1*cp2 != 0 {
+		if math.Abs(hp1-hp2) > 180 {
+			if hp < 360 {
+				hp += 360
+			} else {
+				hp -= 360
+			}
+		}
+		hp /= 2
+	}
+
+	t := 1 - 0.17*math.Cos(rad(hp-30)) + 0.24*math.Cos(rad(2*hp)) +
+		0.32*math.Cos(rad(3*hp+6)) - 0.20*math.Cos(rad(4*hp-63))
+
+	dTheta := 30 * math.Exp(-math.Pow((hp-275)/25, 2))
+	rc := 2 * math.Sqrt(math.Pow(cp, 7)/(math.Pow(cp, 7)+math.Pow(25, 7)))
+	sl := 1 + 0.015*math.Pow(lp-50, 2)/math.Sqrt(20+math.Pow(lp-50, 2))
+	sc := 1 + 0.045*cp
+	sh := 1 + 0.015*cp*t
+	rt := -math.Sin(rad(2*dTheta)) * rc
+
+	return math.Sqrt(math.Pow(dl/sl, 2) + math.Pow(dc/sc, 2) + math.Pow(dH/sh, 2) +
+		rt*(dc/sc)*(dH/sh))
+}
@@ -0,0 +1,150 @@
+package imgdiff
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+type Result struct {
+	// Difference is the mean absolute RGB difference in percent. Pixels
+	// outside the area covered by both images count as completely different.
+	Difference float64 `json:"difference"`
+	// Pixels is the number of pixels that differ, including the pixels
+	// outside the overlapping area.
+	Pixels int `json:"pixels"`
+	// Total is the number of pixels covered by either image, or both. Area
+	// that neither image covers isn't counted.
+	Total int `json:"total"`
+	// SizeDelta is the size of the second image minus the size of the first.
+	SizeDelta image.Point `json:"sizeDelta"`
+}
+
+// ToNRGBA converts an image to non-premultiplied RGBA so that images with
+// different color models can be compared.
+func ToNRGBA(img image.Image) *image.NRGBA {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return img
+	case *image.RGBA:
+		return rgbaToNRGBA(img)
+	}
+
+	b := img.Bounds()
+	n := image.NewNRGBA(b)
+	draw.Draw(n, b, img, b.Min, draw.Src)
+	return n
+}
+
+// Compare compares the overlapping area of a and b. Images of different
+// sizes or color models are compared as far as they overlap and the rest is
+// counted as changed.
+func Compare(a, b image.Image, tolerance uint8) Result {
+	n1, n2 := ToNRGBA(a), ToNRGBA(b)
+	b1, b2 := n1.Bounds(), n2.Bounds()
+
+	result := Result{
+		SizeDelta: b2.Size().Sub(b1.Size()),
+	}
+
+	overlap := b1.Intersect(b2)
+	result.Total = covered(b1, b2)
+	if result.Total == 0 {
+		return result
+	}
+
+	var (
+		mu     sync.Mutex
+		sum    int64
+		pixels int
+	)
+	parallelRows(overlap.Min.Y, overlap.Max.Y, func(y0, y1 int) {
+		var rowSum int64
+		rowPixels := 0
+
+		for y := y0; y < y1; y++ {
+			p1 := n1.Pix[n1.PixOffset(overlap.Min.X, y):]
+			p2 := n2.Pix[n2.PixOffset(overlap.Min.X, y):]
+
+			for i := 0; i < 4*overlap.Dx(); i += 4 {
+				var d uint8
+				for c := 0; c < 3; c++ {
+					cd := absDiff(p1[i+c], p2[i+c])
+					rowSum += int64(cd)
+					if cd > d {
+						d = cd
+					}
+				}
+				if a := absDiff(p1[i+3], p2[i+3]); a > d {
+					d = a
+				}
+
+				if d > tolerance {
+					rowPixels++
+				}
+			}
+		}
+
+		mu.Lock()
+		sum += rowSum
+		pixels += rowPixels
+		mu.Unlock()
+	})
+	result.Pixels = pixels
+
+	outside := result.Total - overlap.Dx()*overlap.Dy()
+	result.Pixels += outside
+	sum += int64(outside) * 0xff * 3
+
+	result.Difference = float64(sum*100) / (float64(result.Total) * 0xff * 3)
+
+	return result
+}
+
+// covered returns the number of pixels covered by a, b or both.
+func covered(a, b image.Rectangle) int {
+	return area(a) + area(b) - area(a.Intersect(b))
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// outside returns the parts of a and of b that the other image doesn't
+// cover.
+func outside(a, b image.Rectangle) []image.Rectangle {
+	overlap := a.Intersect(b)
+	return append(subtract(a, overlap), subtract(b, overlap)...)
+}
+
+// subtract returns the parts of r outside o, which lies within r or is
+// empty, as strips above, below, left and right of o.
+func subtract(r, o image.Rectangle) []image.Rectangle {
+	if o.Empty() {
+		if r.Empty() {
+			return nil
+		}
+		return []image.Rectangle{r}
+	}
+
+	var result []image.Rectangle
+	for _, s := range []image.Rectangle{
+		{image.Pt(r.Min.X, r.Min.Y), image.Pt(r.Max.X, o.Min.Y)},
+		{image.Pt(r.Min.X, o.Max.Y), image.Pt(r.Max.X, r.Max.Y)},
+		{image.Pt(r.Min.X, o.Min.Y), image.Pt(o.Min.X, o.Max.Y)},
+		{image.Pt(o.Max.X, o.Min.Y), image.Pt(r.Max.X, o.Max.Y)},
+	} {
+		if !s.Empty() {
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
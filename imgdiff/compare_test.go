@@ -0,0 +1,88 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func filled(r image.Rectangle, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompareSameSize(t *testing.T) {
+	a := filled(image.Rect(0, 0, 10, 10), color.White)
+	b := filled(image.Rect(0, 0, 10, 10), color.White)
+	b.Set(3, 4, color.Black)
+	b.Set(5, 6, color.NRGBA{0xf8, 0xf8, 0xf8, 0xff})
+
+	if r := Compare(a, a, 0); r.Pixels != 0 || r.Total != 100 || r.Difference != 0 {
+		t.Errorf("identical: %+v", r)
+	}
+
+	if r := Compare(a, b, 0); r.Pixels != 2 || r.Total != 100 {
+		t.Errorf("2 pixels changed: %+v", r)
+	}
+
+	if r := Compare(a, b, 8); r.Pixels != 1 {
+		t.Errorf("tolerance 8: %+v", r)
+	}
+}
+
+func TestCompareSizes(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		a, b   image.Rectangle
+		pixels int
+		total  int
+	}{
+		// Only the extra rows of the taller image differ.
+		{"taller", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 15), 50, 150},
+		// A wide and a tall image cover a cross, not the square around it.
+		{"cross", image.Rect(0, 0, 100, 10), image.Rect(0, 0, 10, 100), 1800, 1900},
+		// Offset images overlap in a corner.
+		{"offset", image.Rect(0, 0, 10, 10), image.Rect(5, 5, 15, 15), 150, 175},
+		{"apart", image.Rect(0, 0, 10, 10), image.Rect(20, 20, 30, 30), 200, 200},
+	} {
+		a, b := filled(c.a, color.White), filled(c.b, color.White)
+
+		r := Compare(a, b, 0)
+		if r.Pixels != c.pixels || r.Total != c.total {
+			t.Errorf("%v: got %d of %d pixels, want %d of %d", c.name, r.Pixels, r.Total, c.pixels, c.total)
+		}
+
+		want := 100 * float64(c.pixels) / float64(c.total)
+		if r.Difference < want-1e-9 || r.Difference > want+1e-9 {
+			t.Errorf("%v: difference %f, want %f", c.name, r.Difference, want)
+		}
+
+		pixels := 0
+		for _, o := range outside(c.a, c.b) {
+			pixels += area(o)
+			if o.Intersect(c.a).Empty() == o.Intersect(c.b).Empty() {
+				t.Errorf("%v: %v is not covered by exactly one image", c.name, o)
+			}
+		}
+		if pixels != c.pixels {
+			t.Errorf("%v: outside covers %d pixels, want %d", c.name, pixels, c.pixels)
+		}
+	}
+}
+
+func TestCompareColorModels(t *testing.T) {
+	a := filled(image.Rect(0, 0, 4, 4), color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	b := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range b.Pix {
+		b.Pix[i] = 0x80
+	}
+
+	if r := Compare(a, b, 0); r.Pixels != 0 {
+		t.Errorf("gray and NRGBA: %+v", r)
+	}
+}
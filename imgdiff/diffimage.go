@@ -0,0 +1,83 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+var (
+	changedColor = color.NRGBA{0xff, 0x00, 0x00, 0xff}
+	outsideColor = color.NRGBA{0xff, 0xa0, 0xa0, 0xff}
+	regionColor  = color.NRGBA{0xff, 0x00, 0xff, 0xff}
+)
+
+// Fade renders an image as a light grayscale version of itself, to be used
+// as the background of a diff image.
+func Fade(img image.Image) *image.NRGBA {
+	n := ToNRGBA(img)
+	b := n.Bounds()
+
+	faded := image.NewNRGBA(b)
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				p := pixelAt(n, x, y)
+				r, g, bl := blended(p)
+				luma := 0.299*r + 0.587*g + 0.114*bl
+				v := uint8(255 - (255-luma)*0.1)
+				faded.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+			}
+		}
+	})
+
+	return faded
+}
+
+// Highlight renders the difference between a and b on top of a faded b.
+// Pixels that differ by more than the tolerance are red and the area
+// covered by only one of the images is light red.
+func Highlight(a, b image.Image, tolerance uint8) *image.NRGBA {
+	n1, n2 := ToNRGBA(a), ToNRGBA(b)
+	overlap := n1.Bounds().Intersect(n2.Bounds())
+	union := n1.Bounds().Union(n2.Bounds())
+
+	// Area that neither image covers stays transparent.
+	result := image.NewNRGBA(union)
+	for _, r := range outside(n1.Bounds(), n2.Bounds()) {
+		draw.Draw(result, r, image.NewUniform(outsideColor), image.ZP, draw.Src)
+	}
+	draw.Draw(result, overlap, Fade(n2), overlap.Min, draw.Src)
+
+	differs, _ := newPixelsDiffer(RGB, float64(tolerance))
+	parallelRows(overlap.Min.Y, overlap.Max.Y, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := overlap.Min.X; x < overlap.Max.X; x++ {
+				if differs(pixelAt(n1, x, y), pixelAt(n2, x, y)) {
+					result.SetNRGBA(x, y, changedColor)
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// Outline draws the bounding box of each region.
+func Outline(img draw.Image, regions []Region) {
+	for _, region := range regions {
+		r := region.Bounds.Intersect(img.Bounds())
+		if r.Empty() {
+			continue
+		}
+
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, r.Min.Y, regionColor)
+			img.Set(x, r.Max.Y-1, regionColor)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			img.Set(r.Min.X, y, regionColor)
+			img.Set(r.Max.X-1, y, regionColor)
+		}
+	}
+}
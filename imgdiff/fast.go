@@ -0,0 +1,112 @@
+package imgdiff
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// Parallelism is the number of goroutines that compare rows of pixels
+// concurrently. Zero uses one per CPU.
+var Parallelism = 0
+
+// Below this many rows the images are compared on a single goroutine.
+const minRowsPerWorker = 64
+
+// parallelRows calls fn for consecutive ranges of the rows [y0, y1), spread
+// over several goroutines, and waits for all of them to return.
+func parallelRows(y0, y1 int, fn func(y0, y1 int)) {
+	n := Parallelism
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	n = min(n, (y1-y0)/minRowsPerWorker)
+
+	if n <= 1 {
+		fn(y0, y1)
+		return
+	}
+
+	var wg sync.WaitGroup
+	step := (y1 - y0 + n - 1) / n
+	for y := y0; y < y1; y += step {
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			fn(y0, y1)
+		}(y, min(y+step, y1))
+	}
+	wg.Wait()
+}
+
+// rgbaFunc returns the same premultiplied 16-bit values as
+// img.At(x, y).RGBA().
+type rgbaFunc func(x, y int) (r, g, b, a uint32)
+
+// pixelReader returns a function that reads pixels straight from the Pix
+// slice of RGBA and NRGBA images, also when they are wrapped by align, which
+// avoids allocating a color for every pixel. Other images are read through
+// At.
+func pixelReader(img image.Image) rgbaFunc {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			return uint32(p[0]) * 0x101, uint32(p[1]) * 0x101, uint32(p[2]) * 0x101, uint32(p[3]) * 0x101
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := img.Pix[img.PixOffset(x, y):]
+			a := uint32(p[3]) * 0x101
+			return uint32(p[0]) * 0x101 * a / 0xffff,
+				uint32(p[1]) * 0x101 * a / 0xffff,
+				uint32(p[2]) * 0x101 * a / 0xffff,
+				a
+		}
+	case transposed:
+		inner := pixelReader(img.Image)
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return inner(y, x)
+		}
+	case window:
+		inner := pixelReader(img.Image)
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return inner(x+img.dx, y+img.dy)
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.At(x, y).RGBA()
+		}
+	}
+}
+
+// rgbaToNRGBA converts a premultiplied RGBA image the same way draw.Draw
+// does, but without going through the color interfaces.
+func rgbaToNRGBA(src *image.RGBA) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			s := src.Pix[src.PixOffset(b.Min.X, y):]
+			d := dst.Pix[dst.PixOffset(b.Min.X, y):]
+			for i := 0; i < 4*b.Dx(); i += 4 {
+				a := uint32(s[i+3])
+				switch a {
+				case 0xff:
+					copy(d[i:i+4], s[i:i+4])
+				case 0:
+					d[i], d[i+1], d[i+2], d[i+3] = 0, 0, 0, 0
+				default:
+					a16 := a * 0x101
+					d[i] = uint8((uint32(s[i]) * 0x101 * 0xffff / a16) >> 8)
+					d[i+1] = uint8((uint32(s[i+1]) * 0x101 * 0xffff / a16) >> 8)
+					d[i+2] = uint8((uint32(s[i+2]) * 0x101 * 0xffff / a16) >> 8)
+					d[i+3] = uint8(a)
+				}
+			}
+		}
+	})
+
+	return dst
+}
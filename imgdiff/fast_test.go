@@ -0,0 +1,115 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"testing"
+)
+
+// opaque hides the concrete type of an image, so that it is read through
+// the generic image.Image interface.
+type opaque struct {
+	image.Image
+}
+
+var (
+	pagesOnce                             sync.Once
+	benchPage, benchChanged, benchShifted *image.RGBA
+)
+
+// benchmarkPages generates a full-page sized capture with rows of text-like
+// blocks, a copy in which a few blocks changed and a copy in which a banner
+// was inserted near the top. They are generated once for all benchmarks.
+func benchmarkPages() (page, changed, shifted *image.RGBA) {
+	pagesOnce.Do(func() {
+		w, h := 1400, 20000
+
+		page := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := uint8(0xff)
+				if (y/20)%3 != 0 && (x/8+y/20)%5 != 0 {
+					v = uint8(x*7 + y*13 + (y>>8)*31)
+				}
+				page.SetRGBA(x, y, color.RGBA{v, v, v, 0xff})
+			}
+		}
+
+		red := image.NewUniform(color.RGBA{0xcc, 0x00, 0x00, 0xff})
+
+		changed := image.NewRGBA(page.Bounds())
+		draw.Draw(changed, changed.Bounds(), page, image.ZP, draw.Src)
+		for y := 1000; y < h; y += 5000 {
+			draw.Draw(changed, image.Rect(100, y, 400, y+200), red, image.ZP, draw.Src)
+		}
+
+		shifted := image.NewRGBA(page.Bounds())
+		draw.Draw(shifted, image.Rect(0, 0, w, 200), page, image.ZP, draw.Src)
+		draw.Draw(shifted, image.Rect(0, 200, w, 280), red, image.ZP, draw.Src)
+		draw.Draw(shifted, image.Rect(0, 280, w, h), page, image.Pt(0, 200), draw.Src)
+
+		benchPage, benchChanged, benchShifted = page, changed, shifted
+	})
+
+	return benchPage, benchChanged, benchShifted
+}
+
+// benchmarkModes runs fn through the generic image.Image interface and
+// through the Pix slices, on one and on all CPUs.
+func benchmarkModes(b *testing.B, other func(changed, shifted *image.RGBA) *image.RGBA, fn func(a, b image.Image)) {
+	page, changed, shifted := benchmarkPages()
+	target := other(changed, shifted)
+
+	modes := []struct {
+		name        string
+		opaque      bool
+		parallelism int
+	}{
+		{"interface", true, 1},
+		{"pix", false, 1},
+		{"pix-parallel", false, 0},
+	}
+
+	defer func(p int) { Parallelism = p }(Parallelism)
+	for _, mode := range modes {
+		var x, y image.Image = page, target
+		if mode.opaque {
+			x, y = opaque{x}, opaque{y}
+		}
+
+		b.Run(mode.name, func(b *testing.B) {
+			Parallelism = mode.parallelism
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fn(x, y)
+			}
+		})
+	}
+}
+
+func changedPage(changed, shifted *image.RGBA) *image.RGBA { return changed }
+func shiftedPage(changed, shifted *image.RGBA) *image.RGBA { return shifted }
+
+func BenchmarkCompare(b *testing.B) {
+	benchmarkModes(b, changedPage, func(x, y image.Image) { Compare(x, y, 0) })
+}
+
+func BenchmarkRegions(b *testing.B) {
+	opts := RegionOptions{Gap: DefaultRegionOptions.Gap}
+	benchmarkModes(b, changedPage, func(x, y image.Image) { Regions(x, y, opts) })
+}
+
+func BenchmarkTolerance(b *testing.B) {
+	tolerance, err := NewMetric("tolerance", MetricOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkModes(b, changedPage, func(x, y image.Image) { tolerance.Compare(x, y) })
+}
+
+func BenchmarkAlign(b *testing.B) {
+	opts := RegionOptions{Gap: DefaultRegionOptions.Gap}
+	benchmarkModes(b, shiftedPage, func(x, y image.Image) { Align(x, y, opts) })
+}
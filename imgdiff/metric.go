@@ -0,0 +1,218 @@
+package imgdiff
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+)
+
+// Score is the outcome of comparing two images with a metric. Value is a
+// distance: 0 means identical and larger values mean more different. Pixel
+// based metrics report the percentage of differing pixels, the SSIM metrics
+// report 1 - similarity.
+type Score struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Pass      bool    `json:"pass"`
+	Pixels    int     `json:"pixels"`
+	Total     int     `json:"total"`
+	Output    string  `json:"output,omitempty"`
+
+	// verdict is the pass or fail of metrics that decide it themselves.
+	verdict *bool
+}
+
+type Metric interface {
+	Name() string
+	Compare(a, b image.Image) (Score, error)
+}
+
+type MetricOptions struct {
+	// Tolerance is the per-channel difference (0-255) that is ignored by the
+	// tolerance and antialias metrics when comparing in RGB.
+	Tolerance uint8
+	// ColorSpace selects how the tolerance and antialias metrics measure the
+	// difference between two pixels.
+	ColorSpace ColorSpace
+	// ColorDistance is the largest YIQ or CIEDE2000 distance for which two
+	// pixels are still considered equal. Zero selects the default.
+	ColorDistance float64
+}
+
+func (o MetricOptions) pixelsDiffer() (pixelsDiffer, error) {
+	if o.ColorSpace == RGB || o.ColorSpace == "" {
+		return newPixelsDiffer(RGB, float64(o.Tolerance))
+	}
+
+	d := o.ColorDistance
+	if d == 0 {
+		d = DefaultColorDistance[o.ColorSpace]
+	}
+
+	return newPixelsDiffer(o.ColorSpace, d)
+}
+
+const DefaultMetric = "perceptual"
+
+var metrics = map[string]func(MetricOptions) Metric{
+	"exact":      func(MetricOptions) Metric { return Exact{} },
+	"tolerance":  func(o MetricOptions) Metric { return Tolerance{o} },
+	"antialias":  func(o MetricOptions) Metric { return AntiAlias{o} },
+	"ssim":       func(MetricOptions) Metric { return SSIM{} },
+	"msssim":     func(MetricOptions) Metric { return MSSSIM{} },
+	"perceptual": func(MetricOptions) Metric { return Perceptual{} },
+}
+
+func NewMetric(name string, opts MetricOptions) (Metric, error) {
+	m, ok := metrics[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", name)
+	}
+
+	if _, err := opts.pixelsDiffer(); err != nil {
+		return nil, err
+	}
+
+	return m(opts), nil
+}
+
+func MetricNames() []string {
+	var names []string
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Evaluate compares a and b with the metric and checks the score against
+// the threshold. Without a threshold, metrics that decide themselves, like
+// pdiff with its own threshold of 100 pixels, keep their verdict.
+func Evaluate(m Metric, a, b image.Image, threshold float64) (Score, error) {
+	s, err := m.Compare(a, b)
+	if err != nil {
+		return s, err
+	}
+
+	s.Threshold = threshold
+	s.Pass = s.Value <= threshold
+	if threshold == 0 && s.verdict != nil {
+		s.Pass = *s.verdict
+	}
+
+	return s, nil
+}
+
+// Exact counts every pixel that is not identical.
+type Exact struct{}
+
+func (Exact) Name() string {
+	return "exact"
+}
+
+func (Exact) Compare(a, b image.Image) (Score, error) {
+	r := Compare(a, b, 0)
+	return pixelScore("exact", r.Pixels, r.Total), nil
+}
+
+// Tolerance counts the pixels that differ by more than the tolerance or
+// color distance.
+type Tolerance struct {
+	MetricOptions
+}
+
+func (Tolerance) Name() string {
+	return "tolerance"
+}
+
+func (t Tolerance) Compare(a, b image.Image) (Score, error) {
+	differs, err := t.pixelsDiffer()
+	if err != nil {
+		return Score{}, err
+	}
+
+	pixels, total := countPixels(a, b, func(n1, n2 *image.NRGBA, x, y int) bool {
+		return differs(pixelAt(n1, x, y), pixelAt(n2, x, y))
+	})
+
+	return pixelScore("tolerance", pixels, total), nil
+}
+
+// AntiAlias works like Tolerance but ignores the pixels that are part of an
+// anti-aliased edge in either image, which tend to flip between captures
+// because of font smoothing and sub-pixel rendering.
+type AntiAlias struct {
+	MetricOptions
+}
+
+func (AntiAlias) Name() string {
+	return "antialias"
+}
+
+func (t AntiAlias) Compare(a, b image.Image) (Score, error) {
+	differs, err := t.pixelsDiffer()
+	if err != nil {
+		return Score{}, err
+	}
+
+	pixels, total := countPixels(a, b, func(n1, n2 *image.NRGBA, x, y int) bool {
+		if !differs(pixelAt(n1, x, y), pixelAt(n2, x, y)) {
+			return false
+		}
+
+		bounds := n1.Bounds().Intersect(n2.Bounds())
+		return !antialiased(n1, n2, x, y, bounds) && !antialiased(n2, n1, x, y, bounds)
+	})
+
+	return pixelScore("antialias", pixels, total), nil
+}
+
+// countPixels counts the pixels in the overlapping area of a and b for
+// which differs returns true, plus all pixels outside the overlapping area.
+// differs is called from several goroutines.
+func countPixels(a, b image.Image, differs func(n1, n2 *image.NRGBA, x, y int) bool) (int, int) {
+	n1, n2 := ToNRGBA(a), ToNRGBA(b)
+	overlap := n1.Bounds().Intersect(n2.Bounds())
+	total := covered(n1.Bounds(), n2.Bounds())
+
+	var mu sync.Mutex
+	pixels := total - area(overlap)
+	parallelRows(overlap.Min.Y, overlap.Max.Y, func(y0, y1 int) {
+		n := 0
+		for y := y0; y < y1; y++ {
+			for x := overlap.Min.X; x < overlap.Max.X; x++ {
+				if differs(n1, n2, x, y) {
+					n++
+				}
+			}
+		}
+
+		mu.Lock()
+		pixels += n
+		mu.Unlock()
+	})
+
+	return pixels, total
+}
+
+func pixelAt(n *image.NRGBA, x, y int) []uint8 {
+	i := n.PixOffset(x, y)
+	return n.Pix[i : i+4]
+}
+
+func pixelScore(metric string, pixels, total int) Score {
+	s := Score{
+		Metric: metric,
+		Pixels: pixels,
+		Total:  total,
+	}
+
+	if total > 0 {
+		s.Value = float64(pixels*100) / float64(total)
+	}
+
+	return s
+}
@@ -0,0 +1,53 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMetricsSizes(t *testing.T) {
+	// A wide and a tall image cover a cross of 1900 pixels, 1800 of them
+	// by only one image.
+	a := filled(image.Rect(0, 0, 100, 10), color.White)
+	b := filled(image.Rect(0, 0, 10, 100), color.White)
+
+	for _, name := range []string{"exact", "tolerance", "antialias"} {
+		m, err := NewMetric(name, MetricOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := Evaluate(m, a, b, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Pixels != 1800 || s.Total != 1900 || s.Pass {
+			t.Errorf("%v: got %d of %d pixels, pass %v", name, s.Pixels, s.Total, s.Pass)
+		}
+	}
+
+	s, err := Evaluate(SSIM{}, a, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Total != 1900 {
+		t.Errorf("ssim: total %d", s.Total)
+	}
+}
+
+func TestEvaluateThreshold(t *testing.T) {
+	a := filled(image.Rect(0, 0, 10, 10), color.White)
+	b := filled(image.Rect(0, 0, 10, 10), color.White)
+	b.Set(0, 0, color.Black)
+
+	for threshold, pass := range map[float64]bool{0: false, 0.5: false, 1: true, 5: true} {
+		s, err := Evaluate(Exact{}, a, b, threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Value != 1 || s.Pass != pass {
+			t.Errorf("threshold %v: %f%%, pass %v", threshold, s.Value, s.Pass)
+		}
+	}
+}
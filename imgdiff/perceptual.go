@@ -0,0 +1,103 @@
+package imgdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// PerceptualCommand returns the command that runs pdiff on two PNG files
+// in dir.
+var PerceptualCommand = func(dir, file1, file2 string) *exec.Cmd {
+	return exec.Command("docker",
+		"run",
+		"--rm",
+		"-v",
+		dir+":/images",
+		"jvdanker/pdiff",
+		"-verbose",
+		file1,
+		file2)
+}
+
+var pdiffPixels = regexp.MustCompile(`(\d+) pixels are different`)
+
+// Perceptual runs the external pdiff tool, which counts the pixels that a
+// human observer would see as different.
+type Perceptual struct{}
+
+func (Perceptual) Name() string {
+	return "perceptual"
+}
+
+func (Perceptual) Compare(a, b image.Image) (Score, error) {
+	score := Score{
+		Metric: "perceptual",
+		Total:  covered(a.Bounds(), b.Bounds()),
+	}
+
+	dir, err := ioutil.TempDir("", "pdiff")
+	if err != nil {
+		return score, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err = writePNG(filepath.Join(dir, "i1.png"), a); err != nil {
+		return score, err
+	}
+
+	if err = writePNG(filepath.Join(dir, "i2.png"), b); err != nil {
+		return score, err
+	}
+
+	cmd := PerceptualCommand(dir, "i1.png", "i2.png")
+	output, err := cmd.CombinedOutput()
+	score.Output = string(output)
+
+	// pdiff exits with 0 when it passes the images.
+	pass := err == nil
+	score.verdict = &pass
+
+	if m := pdiffPixels.FindSubmatch(output); m != nil {
+		score.Pixels, _ = strconv.Atoi(string(m[1]))
+		score.Value = float64(score.Pixels*100) / float64(score.Total)
+		return score, nil
+	}
+
+	// pdiff doesn't count pixels when the images are binary identical. With
+	// -verbose its arguments are printed before the verdict.
+	if err == nil && bytes.Contains(output, []byte("PASS:")) {
+		return score, nil
+	}
+
+	// pdiff fails without counting pixels when it can't compare the images
+	// at all, e.g. because their sizes differ.
+	if _, ok := err.(*exec.ExitError); ok && bytes.Contains(output, []byte("FAIL:")) {
+		score.Pixels = score.Total
+		score.Value = 100
+		return score, nil
+	}
+
+	if err != nil {
+		return score, fmt.Errorf("pdiff: %v: %s", err, output)
+	}
+
+	return score, fmt.Errorf("pdiff: unexpected output: %s", output)
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, img)
+}
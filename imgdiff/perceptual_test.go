@@ -0,0 +1,93 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// pdiffHeader is what pdiff -verbose prints before its verdict.
+const pdiffHeader = `echo "Field of view is 45.000000 degrees"
+echo "Threshold pixels is 100 pixels"
+echo "The Gamma is 2.200000"
+echo "The Display's luminance is 100.000000 candela per meter squared"
+`
+
+// fakePdiff stands in for the pdiff image, it answers like pdiff -verbose
+// does for binary identical images and counts every pixel as different
+// otherwise.
+func fakePdiff(dir, file1, file2 string) *exec.Cmd {
+	script := pdiffHeader + `if cmp -s "$1" "$2"; then echo "PASS: Images are binary identical"; else echo "FAIL: Images are visibly different"; echo "100 pixels are different"; exit 1; fi`
+	return exec.Command("sh", "-c", script, "pdiff", filepath.Join(dir, file1), filepath.Join(dir, file2))
+}
+
+// indistinguishablePdiff answers like pdiff -verbose does for images that
+// differ in fewer pixels than its threshold.
+func indistinguishablePdiff(dir, file1, file2 string) *exec.Cmd {
+	script := pdiffHeader + `echo "PASS: Images are perceptually indistinguishable"; echo "42 pixels are different"`
+	return exec.Command("sh", "-c", script)
+}
+
+func testImage(c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestPerceptualIdentical(t *testing.T) {
+	defer func(c func(dir, file1, file2 string) *exec.Cmd) { PerceptualCommand = c }(PerceptualCommand)
+	PerceptualCommand = fakePdiff
+
+	img := testImage(color.RGBA{200, 10, 10, 255})
+	score, err := Evaluate(Perceptual{}, img, img, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Pixels != 0 || score.Value != 0 || !score.Pass {
+		t.Errorf("identical images: got %d pixels, %f%%, pass %v", score.Pixels, score.Value, score.Pass)
+	}
+}
+
+func TestPerceptualDifferent(t *testing.T) {
+	defer func(c func(dir, file1, file2 string) *exec.Cmd) { PerceptualCommand = c }(PerceptualCommand)
+	PerceptualCommand = fakePdiff
+
+	score, err := Evaluate(Perceptual{}, testImage(color.White), testImage(color.Black), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Pixels != 100 || score.Value != 100 || score.Pass {
+		t.Errorf("different images: got %d pixels, %f%%, pass %v", score.Pixels, score.Value, score.Pass)
+	}
+}
+
+func TestPerceptualIndistinguishable(t *testing.T) {
+	defer func(c func(dir, file1, file2 string) *exec.Cmd) { PerceptualCommand = c }(PerceptualCommand)
+	PerceptualCommand = indistinguishablePdiff
+
+	a, b := testImage(color.White), testImage(color.Black)
+
+	// Without a threshold pdiff decides.
+	score, err := Evaluate(Perceptual{}, a, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Pixels != 42 || !score.Pass {
+		t.Errorf("no threshold: got %d pixels, pass %v", score.Pixels, score.Pass)
+	}
+
+	// A threshold of 10% decides against it.
+	score, err = Evaluate(Perceptual{}, a, b, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score.Pass {
+		t.Errorf("threshold 10%%: %f%% passes", score.Value)
+	}
+}
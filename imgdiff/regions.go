@@ -0,0 +1,195 @@
+package imgdiff
+
+import (
+	"encoding/json"
+	"image"
+)
+
+type Severity string
+
+const (
+	Low    Severity = "low"
+	Medium Severity = "medium"
+	High   Severity = "high"
+)
+
+type Region struct {
+	Bounds   image.Rectangle `json:"bounds"`
+	Pixels   int             `json:"pixels"`
+	Severity Severity        `json:"severity"`
+}
+
+func (r Region) MarshalJSON() ([]byte, error) {
+	type bounds struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}
+
+	return json.Marshal(struct {
+		Bounds   bounds   `json:"bounds"`
+		Pixels   int      `json:"pixels"`
+		Severity Severity `json:"severity"`
+	}{
+		Bounds:   bounds{r.Bounds.Min.X, r.Bounds.Min.Y, r.Bounds.Dx(), r.Bounds.Dy()},
+		Pixels:   r.Pixels,
+		Severity: r.Severity,
+	})
+}
+
+type RegionOptions struct {
+	// Tolerance is the largest per-channel difference (0-255) for which two
+	// pixels are still considered equal.
+	Tolerance uint8
+	// Gap is the distance in pixels up to which differing pixels are joined
+	// into the same region.
+	Gap int
+}
+
+var DefaultRegionOptions = RegionOptions{
+	Tolerance: 0,
+	Gap:       4,
+}
+
+// Regions clusters the pixels that differ between a and b into connected
+// regions, ordered from top to bottom. The area covered by only one of the
+// images is added as changed regions of its own.
+func Regions(a, b image.Image, opts RegionOptions) []Region {
+	var regions []Region
+
+	bounds := a.Bounds().Intersect(b.Bounds())
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > 0 && h > 0 {
+		regions = compareRegions(a, b, bounds, opts)
+	}
+
+	for _, r := range outside(a.Bounds(), b.Bounds()) {
+		regions = append(regions, Region{
+			Bounds:   r,
+			Pixels:   r.Dx() * r.Dy(),
+			Severity: High,
+		})
+	}
+
+	return regions
+}
+
+func compareRegions(a, b image.Image, bounds image.Rectangle, opts RegionOptions) []Region {
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// delta holds the largest channel difference of each pixel, or 0 for
+	// pixels that are within tolerance.
+	delta := make([]uint8, w*h)
+	pa, pb := pixelReader(a), pixelReader(b)
+	parallelRows(0, h, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				r1, g1, b1, a1 := pa(bounds.Min.X+x, bounds.Min.Y+y)
+				r2, g2, b2, a2 := pb(bounds.Min.X+x, bounds.Min.Y+y)
+				d := maxDelta(r1, r2, g1, g2, b1, b2, a1, a2)
+				if d > opts.Tolerance {
+					delta[y*w+x] = d
+				}
+			}
+		}
+	})
+
+	return cluster(delta, w, h, bounds.Min, opts.Gap)
+}
+
+func cluster(delta []uint8, w, h int, origin image.Point, gap int) []Region {
+	if gap < 1 {
+		gap = 1
+	}
+
+	var regions []Region
+	visited := make([]bool, len(delta))
+	var stack []int
+
+	for start, d := range delta {
+		if d == 0 || visited[start] {
+			continue
+		}
+
+		visited[start] = true
+		stack = append(stack[:0], start)
+
+		r := image.Rectangle{
+			Min: image.Point{start % w, start / w},
+			Max: image.Point{start%w + 1, start/w + 1},
+		}
+		pixels := 0
+		sum := 0
+
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			x, y := i%w, i/w
+			pixels++
+			sum += int(delta[i])
+			r = r.Union(image.Rect(x, y, x+1, y+1))
+
+			for ny := max(y-gap, 0); ny <= min(y+gap, h-1); ny++ {
+				for nx := max(x-gap, 0); nx <= min(x+gap, w-1); nx++ {
+					n := ny*w + nx
+					if delta[n] != 0 && !visited[n] {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		regions = append(regions, Region{
+			Bounds:   r.Add(origin),
+			Pixels:   pixels,
+			Severity: severity(float64(sum) / float64(pixels) / 0xff),
+		})
+	}
+
+	return regions
+}
+
+func severity(mean float64) Severity {
+	switch {
+	case mean >= 0.5:
+		return High
+	case mean >= 0.15:
+		return Medium
+	default:
+		return Low
+	}
+}
+
+// maxDelta returns the largest difference between the channel pairs, scaled
+// down to 8 bits.
+func maxDelta(v ...uint32) uint8 {
+	var m uint32
+	for i := 0; i+1 < len(v); i += 2 {
+		d := v[i] - v[i+1]
+		if v[i] < v[i+1] {
+			d = v[i+1] - v[i]
+		}
+		if d > m {
+			m = d
+		}
+	}
+
+	return uint8(m >> 8)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
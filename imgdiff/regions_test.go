@@ -0,0 +1,118 @@
+package imgdiff
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// block paints r on img in c.
+func block(img *image.NRGBA, r image.Rectangle, c color.Color) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+func TestRegionsGap(t *testing.T) {
+	a := filled(image.Rect(0, 0, 40, 40), color.White)
+	b := filled(image.Rect(0, 0, 40, 40), color.White)
+
+	// Two blocks 4 pixels apart and one far below them.
+	block(b, image.Rect(2, 2, 4, 4), color.Black)
+	block(b, image.Rect(7, 2, 9, 4), color.Black)
+	block(b, image.Rect(2, 30, 4, 32), color.Black)
+
+	for _, c := range []struct {
+		gap  int
+		want []image.Rectangle
+	}{
+		{0, []image.Rectangle{image.Rect(2, 2, 4, 4), image.Rect(7, 2, 9, 4), image.Rect(2, 30, 4, 32)}},
+		{3, []image.Rectangle{image.Rect(2, 2, 4, 4), image.Rect(7, 2, 9, 4), image.Rect(2, 30, 4, 32)}},
+		{4, []image.Rectangle{image.Rect(2, 2, 9, 4), image.Rect(2, 30, 4, 32)}},
+		{30, []image.Rectangle{image.Rect(2, 2, 9, 32)}},
+	} {
+		var got []image.Rectangle
+		pixels := 0
+		for _, r := range Regions(a, b, RegionOptions{Gap: c.gap}) {
+			got = append(got, r.Bounds)
+			pixels += r.Pixels
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("gap %d: got %v, want %v", c.gap, got, c.want)
+		}
+		if pixels != 12 {
+			t.Errorf("gap %d: %d pixels, want 12", c.gap, pixels)
+		}
+	}
+}
+
+func TestRegionsSeverity(t *testing.T) {
+	a := filled(image.Rect(0, 0, 20, 40), color.White)
+	b := filled(image.Rect(0, 0, 20, 40), color.White)
+
+	// From top to bottom: a slight, a moderate and a strong change, and a
+	// region that mixes a strong and a slight change.
+	block(b, image.Rect(2, 2, 6, 6), color.Gray{0xf0})
+	block(b, image.Rect(2, 12, 6, 16), color.Gray{0xc0})
+	block(b, image.Rect(2, 22, 6, 26), color.Black)
+	block(b, image.Rect(2, 32, 4, 36), color.Black)
+	block(b, image.Rect(4, 32, 6, 36), color.Gray{0xf0})
+
+	var got []Severity
+	for _, r := range Regions(a, b, DefaultRegionOptions) {
+		got = append(got, r.Severity)
+	}
+
+	want := []Severity{Low, Medium, High, High}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Differences within tolerance are left out.
+	got = nil
+	for _, r := range Regions(a, b, RegionOptions{Tolerance: 0x10, Gap: 4}) {
+		got = append(got, r.Severity)
+	}
+
+	want = []Severity{Medium, High, High}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tolerance: got %v, want %v", got, want)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for _, c := range []struct {
+		mean float64
+		want Severity
+	}{
+		{0, Low},
+		{0.149, Low},
+		{0.15, Medium},
+		{0.499, Medium},
+		{0.5, High},
+		{1, High},
+	} {
+		if got := severity(c.mean); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.mean, got, c.want)
+		}
+	}
+}
+
+func TestRegionsSizes(t *testing.T) {
+	a := filled(image.Rect(0, 0, 10, 10), color.White)
+	b := filled(image.Rect(0, 0, 10, 12), color.White)
+	b.Set(5, 5, color.Black)
+
+	got := Regions(a, b, DefaultRegionOptions)
+	want := []Region{
+		{Bounds: image.Rect(5, 5, 6, 6), Pixels: 1, Severity: High},
+		{Bounds: image.Rect(0, 10, 10, 12), Pixels: 20, Severity: High},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
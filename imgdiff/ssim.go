@@ -0,0 +1,167 @@
+package imgdiff
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	ssimWindow = 8
+	ssimStride = 4
+	ssimC1     = (0.01 * 255) * (0.01 * 255)
+	ssimC2     = (0.03 * 255) * (0.03 * 255)
+)
+
+// Weights of the scales of MS-SSIM, from full resolution down.
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// SSIM compares the luminance, contrast and structure of the two images in
+// 8x8 windows. Its score is 1 - the mean structural similarity.
+type SSIM struct{}
+
+func (SSIM) Name() string {
+	return "ssim"
+}
+
+func (SSIM) Compare(a, b image.Image) (Score, error) {
+	l1, l2, coverage := lumaPlanes(a, b)
+	_, _, ssim := ssimComponents(l1, l2)
+
+	return similarityScore("ssim", ssim*coverage, a, b), nil
+}
+
+// MSSSIM is the multi-scale variant of SSIM, which compares the images at
+// five successively halved resolutions.
+type MSSSIM struct{}
+
+func (MSSSIM) Name() string {
+	return "msssim"
+}
+
+func (MSSSIM) Compare(a, b image.Image) (Score, error) {
+	l1, l2, coverage := lumaPlanes(a, b)
+
+	result := 1.0
+	weights := 0.0
+	for i, w := range msssimWeights {
+		l, cs, _ := ssimComponents(l1, l2)
+
+		last := i == len(msssimWeights)-1 || l1.w/2 < ssimWindow || l1.h/2 < ssimWindow
+		if last {
+			cs *= l
+		}
+
+		result *= math.Pow(math.Max(cs, 0), w)
+		weights += w
+
+		if last {
+			break
+		}
+
+		l1, l2 = l1.half(), l2.half()
+	}
+
+	// Renormalize when the image was too small for all scales.
+	result = math.Pow(result, 1/weights)
+
+	return similarityScore("msssim", result*coverage, a, b), nil
+}
+
+type plane struct {
+	w, h int
+	v    []float64
+}
+
+func (p plane) half() plane {
+	h := plane{w: p.w / 2, h: p.h / 2}
+	h.v = make([]float64, h.w*h.h)
+	for y := 0; y < h.h; y++ {
+		for x := 0; x < h.w; x++ {
+			i := 2*y*p.w + 2*x
+			h.v[y*h.w+x] = (p.v[i] + p.v[i+1] + p.v[i+p.w] + p.v[i+p.w+1]) / 4
+		}
+	}
+
+	return h
+}
+
+// lumaPlanes returns the luminance of the overlapping area of both images
+// and the fraction of the total area that overlaps.
+func lumaPlanes(a, b image.Image) (plane, plane, float64) {
+	n1, n2 := ToNRGBA(a), ToNRGBA(b)
+	overlap := n1.Bounds().Intersect(n2.Bounds())
+	total := covered(n1.Bounds(), n2.Bounds())
+
+	luma := func(n *image.NRGBA) plane {
+		p := plane{w: overlap.Dx(), h: overlap.Dy()}
+		p.v = make([]float64, p.w*p.h)
+		parallelRows(0, p.h, func(y0, y1 int) {
+			for y := y0; y < y1; y++ {
+				px := n.Pix[n.PixOffset(overlap.Min.X, overlap.Min.Y+y):]
+				for x := 0; x < p.w; x++ {
+					p.v[y*p.w+x] = 0.299*float64(px[4*x]) + 0.587*float64(px[4*x+1]) + 0.114*float64(px[4*x+2])
+				}
+			}
+		})
+		return p
+	}
+
+	coverage := 0.0
+	if total > 0 {
+		coverage = float64(area(overlap)) / float64(total)
+	}
+
+	return luma(n1), luma(n2), coverage
+}
+
+// ssimComponents returns the mean luminance term, the mean contrast-structure
+// term and the mean SSIM over all windows.
+func ssimComponents(p1, p2 plane) (float64, float64, float64) {
+	ww, wh := min(ssimWindow, p1.w), min(ssimWindow, p1.h)
+	if ww == 0 || wh == 0 {
+		return 0, 0, 0
+	}
+
+	var sumL, sumCS, sumSSIM float64
+	n := 0
+
+	for y := 0; y+wh <= p1.h; y += ssimStride {
+		for x := 0; x+ww <= p1.w; x += ssimStride {
+			var s1, s2, s11, s22, s12 float64
+			for wy := y; wy < y+wh; wy++ {
+				for wx := x; wx < x+ww; wx++ {
+					v1, v2 := p1.v[wy*p1.w+wx], p2.v[wy*p2.w+wx]
+					s1 += v1
+					s2 += v2
+					s11 += v1 * v1
+					s22 += v2 * v2
+					s12 += v1 * v2
+				}
+			}
+
+			count := float64(ww * wh)
+			mu1, mu2 := s1/count, s2/count
+			var1 := s11/count - mu1*mu1
+			var2 := s22/count - mu2*mu2
+			cov := s12/count - mu1*mu2
+
+			l := (2*mu1*mu2 + ssimC1) / (mu1*mu1 + mu2*mu2 + ssimC1)
+			cs := (2*cov + ssimC2) / (var1 + var2 + ssimC2)
+
+			sumL += l
+			sumCS += cs
+			sumSSIM += l * cs
+			n++
+		}
+	}
+
+	return sumL / float64(n), sumCS / float64(n), sumSSIM / float64(n)
+}
+
+func similarityScore(metric string, similarity float64, a, b image.Image) Score {
+	return Score{
+		Metric: metric,
+		Value:  1 - similarity,
+		Total:  covered(a.Bounds(), b.Bounds()),
+	}
+}
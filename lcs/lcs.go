@@ -0,0 +1,113 @@
+package lcs
+
+import "sort"
+
+// Match pairs up two equal elements, a[I] == b[J].
+type Match struct {
+	I int
+	J int
+}
+
+// Matches aligns two sequences and returns the pairs of elements that are
+// kept in both, in increasing order. It uses patience diffing: elements that
+// occur exactly once in both sequences anchor the alignment, and the gaps in
+// between are matched recursively.
+func Matches(a, b []uint64) []Match {
+	var m []Match
+	match(a, b, 0, 0, &m)
+	return m
+}
+
+func match(a, b []uint64, ai, bi int, m *[]Match) {
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		*m = append(*m, Match{ai + p, bi + p})
+		p++
+	}
+	a, b = a[p:], b[p:]
+	ai, bi = ai+p, bi+p
+
+	s := 0
+	for s < len(a) && s < len(b) && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	a, b = a[:len(a)-s], b[:len(b)-s]
+
+	if len(a) > 0 && len(b) > 0 {
+		prevA, prevB := 0, 0
+		for _, anchor := range anchors(a, b) {
+			match(a[prevA:anchor.I], b[prevB:anchor.J], ai+prevA, bi+prevB, m)
+			*m = append(*m, Match{ai + anchor.I, bi + anchor.J})
+			prevA, prevB = anchor.I+1, anchor.J+1
+		}
+		if prevA > 0 || prevB > 0 {
+			match(a[prevA:], b[prevB:], ai+prevA, bi+prevB, m)
+		}
+	}
+
+	for i := 0; i < s; i++ {
+		*m = append(*m, Match{ai + len(a) + i, bi + len(b) + i})
+	}
+}
+
+// anchors returns the longest increasing run of elements that are unique in
+// both a and b.
+func anchors(a, b []uint64) []Match {
+	type count struct {
+		a, b int
+		j    int
+	}
+
+	counts := make(map[uint64]*count)
+	for _, v := range a {
+		c, ok := counts[v]
+		if !ok {
+			c = &count{}
+			counts[v] = c
+		}
+		c.a++
+	}
+	for j, v := range b {
+		if c, ok := counts[v]; ok {
+			c.b++
+			c.j = j
+		}
+	}
+
+	var unique []Match
+	for i, v := range a {
+		if c := counts[v]; c.a == 1 && c.b == 1 {
+			unique = append(unique, Match{i, c.j})
+		}
+	}
+
+	// Longest increasing subsequence on J using patience sorting.
+	var piles []int
+	prev := make([]int, len(unique))
+	for k, u := range unique {
+		n := sort.Search(len(piles), func(p int) bool {
+			return unique[piles[p]].J >= u.J
+		})
+		if n > 0 {
+			prev[k] = piles[n-1]
+		} else {
+			prev[k] = -1
+		}
+		if n == len(piles) {
+			piles = append(piles, k)
+		} else {
+			piles[n] = k
+		}
+	}
+
+	if len(piles) == 0 {
+		return nil
+	}
+
+	result := make([]Match, len(piles))
+	for k, i := len(piles)-1, piles[len(piles)-1]; k >= 0; k, i = k-1, prev[i] {
+		result[k] = unique[i]
+	}
+
+	return result
+}
@@ -0,0 +1,68 @@
+package lcs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b []uint64
+		want []Match
+	}{
+		{"empty", nil, []uint64{1, 2}, nil},
+		{"identical", []uint64{1, 2, 3}, []uint64{1, 2, 3}, []Match{{0, 0}, {1, 1}, {2, 2}}},
+		{"inserted", []uint64{1, 2, 3}, []uint64{1, 4, 2, 3}, []Match{{0, 0}, {1, 2}, {2, 3}}},
+		{"removed", []uint64{1, 2, 3, 4}, []uint64{1, 3, 4}, []Match{{0, 0}, {2, 1}, {3, 2}}},
+		{"replaced", []uint64{1, 2, 3}, []uint64{1, 5, 3}, []Match{{0, 0}, {2, 2}}},
+		{"nothing in common", []uint64{1, 2, 3}, []uint64{4, 5, 6}, nil},
+		// The unique elements that stay in order anchor the alignment, the
+		// one that moved to the front isn't matched.
+		{"reordered", []uint64{1, 2, 3, 4, 5}, []uint64{1, 4, 2, 3, 5}, []Match{{0, 0}, {1, 2}, {2, 3}, {4, 4}}},
+		// Repeated elements are matched around the unique ones.
+		{"repeated", []uint64{7, 1, 7, 7, 2, 7}, []uint64{7, 7, 1, 7, 2, 7, 7}, []Match{{0, 0}, {1, 2}, {2, 3}, {4, 4}, {5, 6}}},
+		{"no unique elements", []uint64{1, 1, 2}, []uint64{1, 2, 2}, []Match{{0, 0}, {2, 2}}},
+	} {
+		got := Matches(c.a, c.b)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %v, want %v", c.name, got, c.want)
+		}
+		valid(t, c.name, c.a, c.b, got)
+	}
+}
+
+// valid checks that the matches pair equal elements in increasing order.
+func valid(t *testing.T, name string, a, b []uint64, matches []Match) {
+	for k, m := range matches {
+		if a[m.I] != b[m.J] {
+			t.Errorf("%v: %v pairs %d and %d", name, m, a[m.I], b[m.J])
+		}
+		if k > 0 && (m.I <= matches[k-1].I || m.J <= matches[k-1].J) {
+			t.Errorf("%v: %v after %v", name, m, matches[k-1])
+		}
+	}
+}
+
+func TestMatchesLonger(t *testing.T) {
+	// A page of 100 elements with a block of 10 inserted in the middle and
+	// the last 5 removed.
+	var a, b []uint64
+	for i := uint64(0); i < 100; i++ {
+		a = append(a, i)
+		if i == 50 {
+			for j := uint64(1000); j < 1010; j++ {
+				b = append(b, j)
+			}
+		}
+		if i < 95 {
+			b = append(b, i)
+		}
+	}
+
+	matches := Matches(a, b)
+	valid(t, "longer", a, b, matches)
+	if len(matches) != 95 {
+		t.Errorf("%d matches, want 95", len(matches))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/jvdanker/mug/api"
+	"github.com/jvdanker/mug/capture"
 	"github.com/jvdanker/mug/handler"
 	_ "image/png"
 	"log"
@@ -16,10 +17,14 @@ import (
 func main() {
 	var stop = make(chan os.Signal, 1)
 	var logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
-	var worker = api.NewWorker()
+	var worker = api.NewWorker(capture.NewChrome(capture.DefaultDevTools))
 
 	signal.Notify(stop, os.Interrupt)
 
+	if err := api.FailInterruptedComparisons(); err != nil {
+		logger.Println(err)
+	}
+
 	h := &http.Server{Addr: ":8080", Handler: nil}
 
 	handlers := handler.NewHandlers(stop, worker)
@@ -27,13 +32,45 @@ func main() {
 	handlers.AddHandler("/shutdown", handlers.HandleShutdown)
 	handlers.AddHandler("/list", handlers.HandleListRequests)
 	handlers.AddHandler("/init/", handlers.HandleInitRequests)
+	handlers.AddHandler("/diff/", handlers.HandleDiffRequest)
 	handlers.AddHandler("/pdiff/", handlers.HandlePDiffRequest)
+	handlers.AddHandler("/align/", handlers.HandleAlignDiffRequest)
 	handlers.AddHandler("/scan", handlers.HandleScanAllRequests)
 	handlers.AddHandler("/screenshot/reference/get/", handlers.HandleGetReferenceScreenshot)
 	handlers.AddHandler("/screenshot/scan/", handlers.HandleGetScanScreenshot)
+	handlers.AddHandler("/screenshot/overlay/", handlers.HandleGetOverlayImage)
+	handlers.AddHandler("/report/", handlers.HandleReport)
 	handlers.AddHandler("/url/add", handlers.HandleAddUrl)
+	handlers.AddHandler("/url/discover", handlers.HandleDiscover)
+	handlers.AddHandler("/url/bulk/add", handlers.HandleBulkAdd)
+	handlers.AddHandler("/url/bulk/update", handlers.HandleBulkUpdate)
+	handlers.AddHandler("/url/bulk/delete", handlers.HandleBulkDelete)
+	handlers.AddHandler("/url/export/", handlers.HandleExport)
+	handlers.AddHandler("/url/import/", handlers.HandleImport)
 	handlers.AddHandler("/url/scan/", handlers.HandleScanRequests)
+	handlers.AddHandler("/url/events/", handlers.HandleGetEvents)
+	handlers.AddHandler("/url/dom/", handlers.HandleGetDomChanges)
 	handlers.AddHandler("/url/", handlers.HandleDeleteUrl)
+	handlers.AddHandler("/groups", handlers.HandleListGroups)
+	handlers.AddHandler("/group/save", handlers.HandleSaveGroup)
+	handlers.AddHandler("/group/", handlers.HandleDeleteGroup)
+	handlers.AddHandler("/compare", handlers.HandleCompare)
+	handlers.AddHandler("/comparisons", handlers.HandleListComparisons)
+	handlers.AddHandler("/comparison/", handlers.HandleGetComparison)
+	handlers.AddHandler("/run", handlers.HandleStartRun)
+	handlers.AddHandler("/runs", handlers.HandleListRuns)
+	handlers.AddHandler("/run/", handlers.HandleGetRun)
+	handlers.AddHandler("/webhooks", handlers.HandleListWebhooks)
+	handlers.AddHandler("/webhook/save", handlers.HandleSaveWebhook)
+	handlers.AddHandler("/webhook/test/", handlers.HandleTestWebhook)
+	handlers.AddHandler("/webhook/deliveries/", handlers.HandleListDeliveries)
+	handlers.AddHandler("/webhook/", handlers.HandleDeleteWebhook)
+	handlers.AddHandler("/statuses", handlers.HandleListCommitStatuses)
+	handlers.AddHandler("/status/save", handlers.HandleSaveCommitStatus)
+	handlers.AddHandler("/status/", handlers.HandleDeleteCommitStatus)
+	handlers.AddHandler("/projects", handlers.HandleListProjects)
+	handlers.AddHandler("/project/save", handlers.HandleSaveProject)
+	handlers.AddHandler("/project/", handlers.HandleDeleteProject)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -195,7 +195,7 @@ class App extends Component {
                 });
 
                 if (index > -1) {
-                    urls[index].results = res.output;
+                    urls[index].results = {summary: res.output, score: res.score};
                     this.setState({
                         urls: urls
                     });
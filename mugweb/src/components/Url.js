@@ -46,7 +46,7 @@ class Url extends Component {
                 </StyledUrl>
                 <div>
                     <pre>
-                        {this.props.item.results}
+                        {this.props.item.results && this.props.item.results.summary}
                     </pre>
                 </div>
                 <div>
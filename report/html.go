@@ -0,0 +1,249 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"html/template"
+	"time"
+)
+
+// Image is an image in a HTML report.
+type Image struct {
+	Data   []byte
+	Format store.ImageFormat
+}
+
+// Metric is a named value shown with a page, e.g. the score or the number of
+// changed regions.
+type Metric struct {
+	Name  string
+	Value string
+}
+
+// Page is one url in a HTML report. Images that are not set are left out.
+type Page struct {
+	Case
+	Url       string
+	Metrics   []Metric
+	Reference *Image
+	Current   *Image
+	Overlay   *Image
+}
+
+type htmlPage struct {
+	Page
+	Index     int
+	Status    string
+	Reference string
+	Current   string
+	Overlay   string
+}
+
+type htmlReport struct {
+	Title     string
+	Generated string
+	Total     int
+	Passed    int
+	Warnings  int
+	Failed    int
+	Skipped   int
+	Pages     []htmlPage
+}
+
+// HTML writes a report that needs nothing but a browser: an index.html with
+// the styles and scripts inline and the images next to it in images/. It
+// can be opened from a file:// url.
+func HTML(out Output, title string, pages []Page) error {
+	r := htmlReport{
+		Title:     title,
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Total:     len(pages),
+	}
+
+	for i, p := range pages {
+		hp := htmlPage{
+			Page:   p,
+			Index:  i + 1,
+			Status: statusName(p.Case),
+		}
+
+		switch hp.Status {
+		case "skipped":
+			r.Skipped++
+		case "warning":
+			r.Warnings++
+		case "fail":
+			r.Failed++
+		default:
+			r.Passed++
+		}
+
+		var err error
+		if hp.Reference, err = writeImage(out, i+1, "reference", p.Reference); err != nil {
+			return err
+		}
+		if hp.Current, err = writeImage(out, i+1, "current", p.Current); err != nil {
+			return err
+		}
+		if hp.Overlay, err = writeImage(out, i+1, "overlay", p.Overlay); err != nil {
+			return err
+		}
+
+		r.Pages = append(r.Pages, hp)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := htmlTemplate.Execute(buf, r); err != nil {
+		return err
+	}
+
+	return out.WriteFile("index.html", buf.Bytes())
+}
+
+func statusName(c Case) string {
+	switch {
+	case c.Skipped:
+		return "skipped"
+	case c.Status == store.WARNING:
+		return "warning"
+	case c.Status == store.FAIL:
+		return "fail"
+	default:
+		return "pass"
+	}
+}
+
+func writeImage(out Output, index int, kind string, img *Image) (string, error) {
+	if img == nil || len(img.Data) == 0 {
+		return "", nil
+	}
+
+	ext := string(img.Format)
+	switch img.Format {
+	case "":
+		ext = "png"
+	case store.JPEG:
+		ext = "jpg"
+	}
+
+	name := fmt.Sprintf("images/%d-%s.%s", index, kind, ext)
+	return name, out.WriteFile(name, img.Data)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 0; background: #f4f4f4; color: #222; }
+header { padding: 16px 24px; background: #222; color: #fff; }
+header h1 { margin: 0 0 4px; font-size: 20px; }
+.summary span { margin-right: 16px; }
+.filter { padding: 8px 24px; }
+.page { background: #fff; margin: 12px 24px; padding: 12px 16px; border-left: 6px solid #3a3; }
+.page.warning { border-color: #e90; }
+.page.fail { border-color: #d22; }
+.page.skipped { border-color: #999; }
+.page h2 { margin: 0; font-size: 16px; word-break: break-all; }
+.status { display: inline-block; min-width: 64px; margin-right: 8px; padding: 2px 6px; color: #fff; text-align: center; text-transform: uppercase; font-size: 12px; background: #3a3; }
+.warning .status { background: #e90; }
+.fail .status { background: #d22; }
+.skipped .status { background: #999; }
+.message { white-space: pre-wrap; margin: 8px 0; }
+table.metrics { border-collapse: collapse; margin: 8px 0; font-size: 13px; }
+table.metrics td { padding: 2px 12px 2px 0; }
+.modes button { margin-right: 4px; }
+.modes button.active { font-weight: bold; }
+.view { display: none; margin-top: 8px; }
+.compare[data-mode="side"] .side,
+.compare[data-mode="onion"] .onion,
+.compare[data-mode="slider"] .slider,
+.compare[data-mode="overlay"] .overlay { display: block; }
+.side figure { display: inline-block; vertical-align: top; margin: 0 8px 0 0; }
+.side img, .overlay img { max-width: 100%; border: 1px solid #ccc; }
+.stack { position: relative; display: inline-block; border: 1px solid #ccc; }
+.stack img { display: block; max-width: 100%; }
+.stack img.top { position: absolute; top: 0; left: 0; }
+input[type=range] { width: 300px; }
+body.failures-only .page.pass, body.failures-only .page.skipped { display: none; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<div class="summary">
+<span>{{.Total}} URLs</span>
+<span>{{.Passed}} passed</span>
+<span>{{.Warnings}} warnings</span>
+<span>{{.Failed}} failed</span>
+<span>{{.Skipped}} skipped</span>
+<span>generated {{.Generated}}</span>
+</div>
+</header>
+<div class="filter"><label><input type="checkbox" id="failures-only"> Only show warnings and failures</label></div>
+{{range .Pages}}
+<div class="page {{.Status}}" id="page-{{.Index}}">
+<h2><span class="status">{{.Status}}</span>{{if .Url}}<a href="{{.Url}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</h2>
+{{if .Message}}<div class="message">{{.Message}}</div>{{end}}
+{{if .Metrics}}<table class="metrics">{{range .Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if and .Reference .Current}}
+<div class="compare" data-mode="side">
+<div class="modes">
+<button data-mode="side" class="active">Side by side</button>
+<button data-mode="onion">Onion skin</button>
+<button data-mode="slider">Slider</button>
+{{if .Overlay}}<button data-mode="overlay">Overlay</button>{{end}}
+</div>
+<div class="view side">
+<figure><figcaption>Reference</figcaption><img src="{{.Reference}}"></figure>
+<figure><figcaption>Current</figcaption><img src="{{.Current}}"></figure>
+{{if .Overlay}}<figure><figcaption>Overlay</figcaption><img src="{{.Overlay}}"></figure>{{end}}
+</div>
+<div class="view onion">
+<input type="range" min="0" max="100" value="50" class="opacity">
+<div class="stack"><img src="{{.Reference}}"><img class="top" src="{{.Current}}" style="opacity: 0.5"></div>
+</div>
+<div class="view slider">
+<input type="range" min="0" max="100" value="50" class="position">
+<div class="stack"><img src="{{.Reference}}"><img class="top" src="{{.Current}}" style="clip-path: inset(0 50% 0 0)"></div>
+</div>
+{{if .Overlay}}<div class="view overlay"><img src="{{.Overlay}}"></div>{{end}}
+</div>
+{{else}}
+<div class="side">
+{{if .Reference}}<figure><figcaption>Reference</figcaption><img src="{{.Reference}}"></figure>{{end}}
+{{if .Current}}<figure><figcaption>Current</figcaption><img src="{{.Current}}"></figure>{{end}}
+{{if .Overlay}}<figure><figcaption>Overlay</figcaption><img src="{{.Overlay}}"></figure>{{end}}
+</div>
+{{end}}
+</div>
+{{end}}
+<script>
+document.getElementById('failures-only').addEventListener('change', function(e) {
+	document.body.classList.toggle('failures-only', e.target.checked);
+});
+
+Array.prototype.forEach.call(document.querySelectorAll('.compare'), function(c) {
+	Array.prototype.forEach.call(c.querySelectorAll('.modes button'), function(b) {
+		b.addEventListener('click', function() {
+			c.setAttribute('data-mode', b.getAttribute('data-mode'));
+			Array.prototype.forEach.call(c.querySelectorAll('.modes button'), function(o) {
+				o.classList.toggle('active', o === b);
+			});
+		});
+	});
+
+	c.querySelector('.opacity').addEventListener('input', function(e) {
+		c.querySelector('.onion .top').style.opacity = e.target.value / 100;
+	});
+
+	c.querySelector('.position').addEventListener('input', function(e) {
+		c.querySelector('.slider .top').style.clipPath = 'inset(0 ' + (100 - e.target.value) + '% 0 0)';
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"io"
+	"strings"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Skipped   *struct{}     `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit writes the cases as a JUnit XML test suite. WARNING and FAIL are
+// failures of type "warning" and "fail". Attachments are listed in the
+// system-out of each case in the [[ATTACHMENT|path]] form that Jenkins and
+// GitLab pick up.
+func JUnit(w io.Writer, suite string, cases []Case) error {
+	s := junitSuite{
+		Name:  suite,
+		Tests: len(cases),
+	}
+
+	var total float64
+	for _, c := range cases {
+		jc := junitCase{
+			Name:      c.Name,
+			Classname: c.Group,
+			Time:      fmt.Sprintf("%.3f", c.Duration.Seconds()),
+		}
+		if jc.Classname == "" {
+			jc.Classname = suite
+		}
+		total += c.Duration.Seconds()
+
+		switch {
+		case c.Skipped:
+			jc.Skipped = &struct{}{}
+			s.Skipped++
+		case c.Failed():
+			t := "fail"
+			if c.Status == store.WARNING {
+				t = "warning"
+			}
+			jc.Failure = &junitFailure{
+				Message: firstLine(c.Message),
+				Type:    t,
+				Text:    c.Message,
+			}
+			s.Failures++
+		}
+
+		var out []string
+		for _, a := range c.Attachments {
+			out = append(out, "[[ATTACHMENT|"+a+"]]")
+		}
+		jc.SystemOut = strings.Join(out, "\n")
+
+		s.Cases = append(s.Cases, jc)
+	}
+	s.Time = fmt.Sprintf("%.3f", total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{s}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package report
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output is where a HTML report is written to: a directory or a zip file.
+type Output interface {
+	WriteFile(name string, data []byte) error
+	Close() error
+}
+
+type dirOutput struct {
+	dir string
+}
+
+// NewDirOutput writes the report files to dir, which is created if needed.
+func NewDirOutput(dir string) (Output, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return dirOutput{dir: dir}, nil
+}
+
+func (d dirOutput) WriteFile(name string, data []byte) error {
+	filename := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func (d dirOutput) Close() error {
+	return nil
+}
+
+type zipOutput struct {
+	w *zip.Writer
+}
+
+// NewZipOutput writes the report files as a zip archive to w.
+func NewZipOutput(w io.Writer) Output {
+	return zipOutput{w: zip.NewWriter(w)}
+}
+
+func (z zipOutput) WriteFile(name string, data []byte) error {
+	f, err := z.w.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	return err
+}
+
+func (z zipOutput) Close() error {
+	return z.w.Close()
+}
+
+// IsZip reports whether a report filename asks for a zip archive.
+func IsZip(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".zip")
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"io"
+	"time"
+)
+
+// Case is one checked url in a report.
+type Case struct {
+	Name string
+	// Group is the class name of the test case in JUnit reports.
+	Group  string
+	Status store.StatusType
+	// Skipped cases have not been compared yet.
+	Skipped bool
+	// Message is the diff summary.
+	Message  string
+	Duration time.Duration
+	// Attachments are paths or urls of images, e.g. the overlay.
+	Attachments []string
+}
+
+// Failed reports whether the case counts as a failure: WARNING and FAIL
+// both do.
+func (c Case) Failed() bool {
+	return !c.Skipped && c.Status != store.SUCCESS
+}
+
+// Writer writes a report in one format.
+type Writer func(w io.Writer, suite string, cases []Case) error
+
+var formats = map[string]struct {
+	write       Writer
+	contentType string
+}{
+	"junit": {JUnit, "application/xml"},
+	"tap":   {TAP, "text/plain"},
+}
+
+// Format returns the writer and content type of a report format.
+func Format(name string) (Writer, string, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown report format %q, use junit or tap", name)
+	}
+
+	return f.write, f.contentType, nil
+}
+
+// FromUrls turns the results of the last scan into test cases. overlay
+// returns the path or url of the overlay image of a url.
+func FromUrls(urls []store.Url, overlay func(u store.Url) string) []Case {
+	var cases []Case
+	for _, u := range urls {
+		c := Case{
+			Name:    u.Url,
+			Group:   u.Group,
+			Status:  u.Status,
+			Skipped: u.Current == "" || u.Results.Summary == "",
+			Message: u.Results.Summary,
+		}
+
+		if u.Overlay != "" && overlay != nil {
+			c.Attachments = append(c.Attachments, overlay(u))
+		}
+
+		cases = append(cases, c)
+	}
+
+	return cases
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"io"
+	"strings"
+)
+
+// TAP writes the cases in the Test Anything Protocol, version 13. Failed
+// cases get a YAML block with the status, message and attachments.
+func TAP(w io.Writer, suite string, cases []Case) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(cases))
+	if suite != "" {
+		fmt.Fprintf(&b, "# %s\n", suite)
+	}
+
+	for i, c := range cases {
+		name := strings.Replace(c.Name, "#", "\\#", -1)
+
+		switch {
+		case c.Skipped:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP not compared yet\n", i+1, name)
+			continue
+		case !c.Failed():
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, name)
+			continue
+		}
+
+		status := "fail"
+		if c.Status == store.WARNING {
+			status = "warning"
+		}
+
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, name)
+		fmt.Fprintf(&b, "  ---\n  status: %s\n", status)
+		if c.Message != "" {
+			fmt.Fprintf(&b, "  message: |\n")
+			for _, line := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+		if len(c.Attachments) > 0 {
+			fmt.Fprintf(&b, "  attachments:\n")
+			for _, a := range c.Attachments {
+				fmt.Fprintf(&b, "    - %q\n", a)
+			}
+		}
+		fmt.Fprintf(&b, "  ...\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -1,91 +1,281 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/jvdanker/mug/imgdiff"
 	"image"
+	"image/draw"
 	"image/png"
-	"log"
 	"os"
+	"strings"
+
+	// Decoders for the other input formats.
+	_ "golang.org/x/image/webp"
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// Exit codes: the images are the same, they differ by more than the
+// threshold, or they could not be compared.
+const (
+	exitSame      = 0
+	exitDifferent = 1
+	exitError     = 2
 )
 
+type report struct {
+	Image1     string           `json:"image1"`
+	Image2     string           `json:"image2"`
+	Mode       string           `json:"mode"`
+	Size1      size             `json:"size1"`
+	Size2      size             `json:"size2"`
+	SizeDelta  size             `json:"sizeDelta"`
+	Difference float64          `json:"difference"`
+	Pixels     int              `json:"pixels"`
+	Total      int              `json:"total"`
+	Threshold  float64          `json:"threshold"`
+	Different  bool             `json:"different"`
+	Score      *imgdiff.Score   `json:"score,omitempty"`
+	Shifts     []imgdiff.Shift  `json:"shifts,omitempty"`
+	Regions    []imgdiff.Region `json:"regions"`
+	Output     string           `json:"output,omitempty"`
+}
+
+type size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func newSize(p image.Point) size {
+	return size{p.X, p.Y}
+}
+
 func main() {
 	var (
 		input1    = ""
 		input2    = ""
-		threshold = 0
+		output    = ""
+		format    = "text"
+		threshold = 0.0
+		gap       = imgdiff.DefaultRegionOptions.Gap
+		align     = false
+		metric    = ""
+		tolerance = 0
+		space     = string(imgdiff.RGB)
+		distance  = 0.0
 	)
 
 	flag.StringVar(&input1, "i1", input1, "image 1")
 	flag.StringVar(&input2, "i2", input2, "image 2")
-	flag.IntVar(&threshold, "t", threshold, "threshold")
+	flag.StringVar(&output, "o", output, "write an image highlighting the differences to this PNG file")
+	flag.StringVar(&format, "format", format, "output format: text or json")
+	flag.Float64Var(&threshold, "t", threshold, "threshold, the images differ when the difference or score exceeds it")
+	flag.IntVar(&gap, "gap", gap, "distance in pixels up to which changes are grouped into one area")
+	flag.BoolVar(&align, "align", align, "align rows or columns that shifted before comparing")
+	flag.StringVar(&metric, "metric", metric, "compare with a metric instead: "+strings.Join(imgdiff.MetricNames(), ", "))
+	flag.IntVar(&tolerance, "tolerance", tolerance, "per-channel tolerance (0-255) for the tolerance and antialias metrics")
+	flag.StringVar(&space, "colorspace", space, "color space used to compare pixels: rgb, yiq or ciede2000")
+	flag.Float64Var(&distance, "distance", distance, "largest yiq (0-1) or ciede2000 distance for which pixels are equal, 0 for the default")
 	flag.Parse()
 
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
 	if input1 == "" || input2 == "" {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
-	fmt.Printf("Comparing image %v to %v with threshold set to %v\n", input1, input2, threshold)
+	r := report{
+		Image1:    input1,
+		Image2:    input2,
+		Threshold: threshold,
+		Mode:      "pixel",
+	}
+
+	fail := func(err error) {
+		if format == "json" {
+			printJSON(struct {
+				Image1 string `json:"image1"`
+				Image2 string `json:"image2"`
+				Error  string `json:"error"`
+			}{input1, input2, err.Error()})
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitError)
+	}
 
 	i1, err := loadImage(input1)
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
 	i2, err := loadImage(input2)
 	if err != nil {
-		log.Fatal(err)
+		fail(err)
 	}
 
-	if i1.ColorModel() != i2.ColorModel() {
-		log.Fatal("different color models")
+	r.Size1, r.Size2 = newSize(i1.Bounds().Size()), newSize(i2.Bounds().Size())
+	opts := imgdiff.RegionOptions{Gap: gap}
+
+	var diff *image.NRGBA
+	switch {
+	case align:
+		al := imgdiff.Align(i1, i2, opts)
+		b := i2.Bounds()
+
+		r.Mode = "align"
+		r.Shifts = al.Shifts
+		r.Regions = al.Regions
+		r.Pixels = al.Pixels
+		r.Total = b.Dx() * b.Dy()
+		r.SizeDelta = newSize(i2.Bounds().Size().Sub(i1.Bounds().Size()))
+		if r.Total > 0 {
+			r.Difference = float64(r.Pixels*100) / float64(r.Total)
+		}
+		r.Different = r.Difference > threshold
+
+		// Content moved, so a pixel by pixel highlight would mark everything
+		// below a shift; only outline what still differs after aligning.
+		if output != "" {
+			diff = imgdiff.Fade(i2)
+		}
+
+	case metric != "":
+		m, err := imgdiff.NewMetric(metric, imgdiff.MetricOptions{
+			Tolerance:     uint8(tolerance),
+			ColorSpace:    imgdiff.ColorSpace(space),
+			ColorDistance: distance,
+		})
+		if err != nil {
+			fail(err)
+		}
+
+		score, err := imgdiff.Evaluate(m, i1, i2, threshold)
+		if err != nil {
+			fail(err)
+		}
+
+		r.Mode = "metric"
+		r.Output, score.Output = score.Output, ""
+		r.Score = &score
+		r.Pixels = score.Pixels
+		r.Total = score.Total
+		r.Difference = score.Value
+		r.SizeDelta = newSize(imgdiff.Compare(i1, i2, 0).SizeDelta)
+		r.Regions = imgdiff.Regions(i1, i2, opts)
+		r.Different = !score.Pass
+
+		if output != "" {
+			diff = imgdiff.Highlight(i1, i2, uint8(tolerance))
+		}
+
+	default:
+		result := imgdiff.Compare(i1, i2, 0)
+
+		r.Difference = result.Difference
+		r.Pixels = result.Pixels
+		r.Total = result.Total
+		r.SizeDelta = newSize(result.SizeDelta)
+		r.Regions = imgdiff.Regions(i1, i2, opts)
+		r.Different = r.Difference > threshold
+
+		if output != "" {
+			diff = imgdiff.Highlight(i1, i2, 0)
+		}
 	}
 
-	b := i1.Bounds()
-	if !b.Eq(i2.Bounds()) {
-		log.Fatal("different image sizes")
+	if diff != nil {
+		imgdiff.Outline(diff, r.Regions)
+		if err := writePNG(output, diff); err != nil {
+			fail(err)
+		}
 	}
 
-	var sum int64
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			r1, g1, b1, _ := i1.At(x, y).RGBA()
-			r2, g2, b2, _ := i2.At(x, y).RGBA()
-			sum += diff(r1, r2)
-			sum += diff(g1, g2)
-			sum += diff(b1, b2)
+	if format == "json" {
+		if r.Regions == nil {
+			r.Regions = []imgdiff.Region{}
 		}
+		printJSON(r)
+	} else {
+		printText(r)
+	}
+
+	if r.Different {
+		os.Exit(exitDifferent)
 	}
+	os.Exit(exitSame)
+}
+
+func printText(r report) {
+	fmt.Printf("Comparing image %v to %v with threshold set to %v\n", r.Image1, r.Image2, r.Threshold)
 
-	nPixels := (b.Max.X - b.Min.X) * (b.Max.Y - b.Min.Y)
-	diff := float64(sum*100) / (float64(nPixels) * 0xffff * 3)
-	fmt.Printf("Image difference: %f%%\n", diff)
+	if r.SizeDelta != (size{}) {
+		fmt.Printf("Image sizes differ: %dx%d vs %dx%d (delta %+d x %+d)\n",
+			r.Size1.Width, r.Size1.Height, r.Size2.Width, r.Size2.Height, r.SizeDelta.Width, r.SizeDelta.Height)
+	}
 
-	if diff > float64(threshold) {
-		os.Exit(1)
+	for _, s := range r.Shifts {
+		fmt.Printf("Content shifted: %v\n", s)
 	}
+
+	switch r.Mode {
+	case "metric":
+		fmt.Printf("%v score: %f (%d of %d pixels differ)\n", r.Score.Metric, r.Score.Value, r.Pixels, r.Total)
+	case "align":
+		fmt.Printf("Image difference after alignment: %f%%\n", r.Difference)
+	default:
+		fmt.Printf("Image difference: %f%%\n", r.Difference)
+		fmt.Printf("Differing pixels: %d of %d\n", r.Pixels, r.Total)
+	}
+
+	fmt.Printf("Changed areas: %d\n", len(r.Regions))
+	for _, region := range r.Regions {
+		fmt.Printf("  %v: %d pixels, %v\n", region.Bounds, region.Pixels, region.Severity)
+	}
+}
+
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+
+	fmt.Println(string(b))
 }
 
 func loadImage(filename string) (image.Image, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer f.Close()
 
-	img, err := png.Decode(f)
+	img, _, err := image.Decode(f)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%v: %v", filename, err)
 	}
 
 	return img, nil
 }
 
-func diff(a, b uint32) int64 {
-	if a > b {
-		return int64(a - b)
+func writePNG(filename string, img draw.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
 	}
 
-	return int64(b - a)
+	return f.Close()
 }
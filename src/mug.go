@@ -4,111 +4,339 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"github.com/chromedp/cdproto/cdp"
-	"github.com/chromedp/cdproto/dom"
-	"github.com/chromedp/cdproto/emulation"
-	"github.com/chromedp/cdproto/network"
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/chromedp"
-	"github.com/chromedp/chromedp/runner"
+	"github.com/jvdanker/mug/batch"
+	"github.com/jvdanker/mug/capture"
+	"github.com/jvdanker/mug/check"
+	"github.com/jvdanker/mug/report"
+	"github.com/jvdanker/mug/store"
 	"io/ioutil"
 	"log"
 	"os"
-	"time"
+	"path/filepath"
+	"strings"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+
 	var (
-		url     = ""
-		output  = ""
-		verbose = false
-		usage   = false
+		url         = ""
+		output      = ""
+		input       = ""
+		dir         = "."
+		name        = batch.DefaultTemplate
+		parallelism = 2
+		format      = ""
+		verbose     = false
+		usage       = false
+		window      = fmt.Sprintf("%dx%d", capture.DefaultOptions.Width, capture.DefaultOptions.Height)
+		browser     = capture.DefaultBrowserOptions
+		opts        = capture.DefaultOptions
 	)
 
 	flag.StringVar(&url, "u", url, "URL")
 	flag.StringVar(&output, "o", output, "Output filename")
-	flag.BoolVar(&verbose, "v", verbose, "Verbose output")
+	flag.StringVar(&input, "i", input, "File with the URLs to capture: text with one URL per line, CSV or YAML")
+	flag.StringVar(&dir, "d", dir, "Output directory for the URLs read with -i")
+	flag.StringVar(&name, "name", name, "Filename template for the URLs read with -i, using {{.Index}}, {{.Host}}, {{.Path}}, {{.Name}}, {{.Slug}} and {{.Ext}}")
+	flag.IntVar(&parallelism, "p", parallelism, "Number of URLs read with -i that are captured at the same time")
+	flag.StringVar(&format, "format", format, "Image format: png, jpeg or webp, defaults to the extension of the output filename")
+	flag.IntVar(&opts.Quality, "quality", opts.Quality, "Quality (0-100) of jpeg and webp images")
+	flag.BoolVar(&browser.Headless, "headless", browser.Headless, "Run Chrome without a window, use -headless=false to show it")
+	flag.StringVar(&browser.Path, "chrome", browser.Path, "Path to the Chrome binary, found automatically if empty")
+	flag.StringVar(&window, "window", window, "Window size as WIDTHxHEIGHT, the width is also the width of the screenshot")
+	flag.Float64Var(&opts.Scale, "scale", opts.Scale, "Device scale factor")
+	flag.StringVar(&opts.UserAgent, "ua", opts.UserAgent, "User agent, Chrome's default if empty")
+	flag.Var((*flagList)(&browser.Flags), "flag", "Extra Chrome flag such as --disable-gpu or --lang=nl, can be repeated")
+	flag.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "Timeout for loading and capturing a page")
+	flag.StringVar(&opts.WaitFor, "wait-for", opts.WaitFor, "After the page has loaded, also wait until the element matching this CSS selector is visible")
+	flag.DurationVar(&opts.Delay, "delay", opts.Delay, "Extra time to wait after the page is ready, e.g. for animations")
+	flag.BoolVar(&verbose, "v", verbose, "Verbose output, prints the console messages and errors of every page")
 	flag.BoolVar(&usage, "?", usage, "Display usage")
 	flag.Parse()
 
-	if url == "" || output == "" || usage {
+	if usage || (input == "" && (url == "" || output == "")) || (input != "" && url != "") {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	fmt.Printf("Create snapshot of %v to %v\n", url, output)
+	if _, err := fmt.Sscanf(window, "%dx%d", &opts.Width, &opts.Height); err != nil {
+		fmt.Printf("Invalid window size %q\n", window)
+		os.Exit(1)
+	}
+	browser.Width, browser.Height = opts.Width, opts.Height
+
+	if format == "" {
+		format = formatFromFilename(output)
+	}
+	opts.Format = store.ImageFormat(format)
+
+	if err := capture.ValidFormat(opts.Format, opts.Quality); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var (
+		entries   []batch.Entry
+		filenames []string
+		err       error
+	)
+	if input != "" {
+		entries, err = batch.Load(input)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	var err error
+		filenames, err = batch.Filenames(name, entries, extension(format))
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	// create context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+		for i := range filenames {
+			filenames[i] = filepath.Join(dir, filenames[i])
+		}
 
-	opts := []chromedp.Option{chromedp.WithRunnerOptions(runner.Flag("headless", false))}
-	if verbose {
-		opts = append(opts, chromedp.WithLog(log.Printf))
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		entries = []batch.Entry{{Url: url}}
+		filenames = []string{output}
+		parallelism = 1
 	}
 
-	// create chrome instance
-	c, err := chromedp.New(ctx, opts...)
+	// start one chrome for all urls
+	b, err := capture.Launch(browser)
 	if err != nil {
 		log.Fatal(err)
 	}
+	capturer := capture.NewChrome(b.DevTools)
+
+	results := batch.Run(entries, filenames, parallelism, func(i int, e batch.Entry, filename string) error {
+		fmt.Printf("Create snapshot of %v to %v\n", e.Url, filename)
+
+		res, err := capturer.Capture(context.Background(), e.Url, opts)
+		if err != nil {
+			return err
+		}
 
-	//enableNetworkEvents(ctx, c)
-	res, err := createSnapshot(ctx, c, url)
+		if verbose {
+			for _, ev := range res.Events {
+				fmt.Printf("  %v %v %v: %v\n", e.Url, ev.Type, ev.Level, ev.Message)
+			}
+		}
+
+		return ioutil.WriteFile(filename, res.Image, 0644)
+	})
 
 	// shutdown chrome
-	err = c.Shutdown(ctx)
+	if err = b.Close(); err != nil {
+		log.Print(err)
+	}
+
+	failed := batch.Failed(results)
+	if input != "" {
+		fmt.Printf("Captured %d of %d URLs, %d failed\n", len(results)-len(failed), len(results), len(failed))
+	}
+	for _, r := range failed {
+		fmt.Printf("FAIL %v: %v\n", r.Entry.Url, r.Err)
+	}
+
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runCheck captures the urls in a config file and compares them with their
+// baselines. It returns 0 when all urls pass, 1 when any of them regressed
+// and 2 when the check could not run.
+func runCheck(args []string) int {
+	var (
+		update     = false
+		format     = ""
+		reportFile = ""
+		baseUrl    = ""
+		fs         = flag.NewFlagSet("check", flag.ExitOnError)
+	)
+
+	fs.BoolVar(&update, "update", update, "Replace the baselines with new captures")
+	fs.StringVar(&baseUrl, "base-url", baseUrl, "Capture every url on this deployment instead, e.g. https://pr-12.example.com/")
+	fs.StringVar(&format, "report", format, "Write a report: junit, tap or html")
+	fs.StringVar(&reportFile, "report-file", reportFile, "Report filename, defaults to report.xml, report.tap or the report directory in the output directory. A HTML report ending in .zip is zipped")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mug check [--update] [--base-url url] [--report junit|tap|html] [config.yaml]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	filename := "mug.yaml"
+	if fs.NArg() > 0 {
+		filename = fs.Arg(0)
+	}
+
+	config, err := check.LoadConfig(filename)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		return 2
 	}
+	config.Override = baseUrl
 
-	// TODO this doesn't work in headless mode
-	// wait for chrome to finish
-	//err = c.Wait()
-	//if err != nil {
-	//	log.Fatal(err)
-	//}
+	var write report.Writer
+	if format != "" && format != "html" {
+		if write, _, err = report.Format(format); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+
+	if reportFile == "" {
+		switch format {
+		case "junit":
+			reportFile = filepath.Join(config.Output, "report.xml")
+		case "tap":
+			reportFile = filepath.Join(config.Output, "report.tap")
+		case "html":
+			reportFile = filepath.Join(config.Output, "report")
+		}
+	}
 
-	err = ioutil.WriteFile(output, res, 0644)
+	b, err := capture.Launch(config.BrowserOptions())
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		return 2
+	}
+	defer b.Close()
+
+	results, err := check.Run(config, capture.NewChrome(b.DevTools), update)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	for _, r := range results {
+		status := "PASS"
+		if r.Status == store.FAIL {
+			status = "FAIL"
+		}
+		fmt.Printf("%s %v (%v): %v\n", status, r.Name, r.Address, r.Message)
+		if r.Diff != "" {
+			fmt.Printf("     diff: %v\n", r.Diff)
+		}
+	}
+
+	failed := check.Failed(results)
+	if update {
+		fmt.Printf("Updated %d of %d baselines in %v\n", len(results)-len(failed), len(results), config.Baselines)
+	} else {
+		fmt.Printf("%d of %d URLs passed, %d failed\n", len(results)-len(failed), len(results), len(failed))
+	}
+
+	if write != nil {
+		err = writeReport(reportFile, write, check.Cases(results))
+	} else if format == "html" {
+		err = writeHtmlReport(reportFile, results)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if format != "" {
+		fmt.Printf("Report written to %v\n", reportFile)
 	}
+
+	if len(failed) > 0 {
+		return 1
+	}
+	return 0
 }
 
-func createSnapshot(ctx context.Context, c *chromedp.CDP, url string) ([]byte, error) {
-	err := c.Run(ctx, chromedp.Tasks{
-		chromedp.Navigate(url),
-		chromedp.Sleep(3 * time.Second),
-		//chromedp.WaitVisible(".content", chromedp.ByQuery),
-	})
+func writeReport(filename string, write report.Writer, cases []report.Case) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	var res []byte
-	af := chromedp.ActionFunc(func(ctxt context.Context, h cdp.Executor) error {
-		var err error
+	if err = write(f, "mug check", cases); err != nil {
+		f.Close()
+		return err
+	}
 
-		root, err := dom.GetDocument().Do(ctxt, h)
-		body, err := dom.QuerySelector(root.NodeID, "body").Do(ctxt, h)
-		bm, err := dom.GetBoxModel().WithNodeID(body).Do(ctxt, h)
-		emulation.SetDeviceMetricsOverride(1400, bm.Height, 1, false).Do(ctxt, h)
-		//emulation.SetVisibleSize
+	return f.Close()
+}
 
-		res, err = page.CaptureScreenshot().WithFromSurface(true).Do(ctxt, h)
+// writeHtmlReport writes a HTML report to a directory, or to a zip file when
+// the filename ends in .zip.
+func writeHtmlReport(filename string, results []check.Result) error {
+	pages, err := check.Pages(results)
+	if err != nil {
+		return err
+	}
+
+	if !report.IsZip(filename) {
+		out, err := report.NewDirOutput(filename)
+		if err != nil {
+			return err
+		}
+		if err = report.HTML(out, "mug check", pages); err != nil {
+			return err
+		}
+		return out.Close()
+	}
 
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
 		return err
-	})
-	err = c.Run(ctx, af)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	out := report.NewZipOutput(f)
+	if err = report.HTML(out, "mug check", pages); err != nil {
+		f.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		f.Close()
+		return err
+	}
 
-	return res, err
+	return f.Close()
 }
 
-func enableNetworkEvents(ctx context.Context, c *chromedp.CDP) error {
-	af := chromedp.ActionFunc(func(ctx context.Context, h cdp.Executor) error {
-		return network.Enable().Do(ctx, h)
-	})
-	return c.Run(ctx, af)
+// flagList collects the values of a flag that can be repeated.
+type flagList []string
+
+func (l *flagList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *flagList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+func formatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".webp":
+		return "webp"
+	default:
+		return "png"
+	}
 }
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"time"
 
-	"github.com/jvdanker/mug/lib"
+	"github.com/jvdanker/mug/capture"
 )
 
 func main() {
-	data, err := lib.Run(5*time.Second, "https://www.govt.nz")
+	browser, err := capture.Launch(capture.DefaultBrowserOptions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer browser.Close()
+
+	result, err := capture.NewChrome(browser.DevTools).Capture(context.Background(), "https://www.govt.nz", capture.DefaultOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	screenshotName := "screenshot.png"
-	if err = ioutil.WriteFile(screenshotName, data, 0644); err != nil {
+	if err = ioutil.WriteFile(screenshotName, result.Image, 0644); err != nil {
 		panic(err)
 	}
 
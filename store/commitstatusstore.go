@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileCommitStatusStore struct {
+	data []CommitStatus
+}
+
+func NewCommitStatusStore() FileCommitStatusStore {
+	return FileCommitStatusStore{}
+}
+
+func (s *FileCommitStatusStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("statuses.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileCommitStatusStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("statuses.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileCommitStatusStore) List() []CommitStatus {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileCommitStatusStore) Get(id int) (*CommitStatus, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileCommitStatusStore) Save(status CommitStatus) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(status.Id)
+	if i != -1 {
+		s.data[i] = status
+	} else {
+		s.data = append(s.data, status)
+	}
+
+	return nil
+}
+
+func (s *FileCommitStatusStore) Delete(id int) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data = append(s.data[:i], s.data[i+1:]...)
+
+	return nil
+}
+
+func (s *FileCommitStatusStore) indexOf(id int) int {
+	for i, item := range s.data {
+		if item.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
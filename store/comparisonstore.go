@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileComparisonStore struct {
+	data []Comparison
+}
+
+func NewComparisonStore() FileComparisonStore {
+	return FileComparisonStore{}
+}
+
+func (s *FileComparisonStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("comparisons.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileComparisonStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("comparisons.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileComparisonStore) List() []Comparison {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileComparisonStore) Get(id int) (*Comparison, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileComparisonStore) Add(comparison Comparison) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.data = append(s.data, comparison)
+
+	return nil
+}
+
+func (s *FileComparisonStore) Update(comparison Comparison) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(comparison.Id)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data[i] = comparison
+
+	return nil
+}
+
+func (s *FileComparisonStore) indexOf(id int) int {
+	for i, item := range s.data {
+		if item.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
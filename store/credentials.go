@@ -0,0 +1,116 @@
+package store
+
+// Redacted returns c without the password and the values of its headers,
+// for responses. Clients send them back empty to keep the stored ones, see
+// Keep.
+func (c *Credentials) Redacted() *Credentials {
+	if c == nil {
+		return nil
+	}
+
+	r := &Credentials{Username: c.Username}
+	if len(c.Headers) > 0 {
+		r.Headers = make(map[string]string)
+		for k := range c.Headers {
+			r.Headers[k] = ""
+		}
+	}
+
+	return r
+}
+
+// Keep fills in the password and the header values that c leaves empty with
+// those of stored, e.g. when c was redacted. The password is only kept for
+// the same user. Headers that stay empty are dropped.
+func (c *Credentials) Keep(stored *Credentials) *Credentials {
+	if c == nil {
+		return nil
+	}
+
+	k := &Credentials{
+		Username: c.Username,
+		Password: c.Password,
+	}
+	if stored != nil && k.Password == "" && k.Username == stored.Username {
+		k.Password = stored.Password
+	}
+
+	for name, value := range c.Headers {
+		if value == "" && stored != nil {
+			value = stored.Headers[name]
+		}
+		if value == "" {
+			continue
+		}
+
+		if k.Headers == nil {
+			k.Headers = make(map[string]string)
+		}
+		k.Headers[name] = value
+	}
+
+	return k
+}
+
+// Redacted returns u without the secrets of its credentials.
+func (u Url) Redacted() Url {
+	u.Credentials = u.Credentials.Redacted()
+	return u
+}
+
+// Redacted returns p without the secrets of its credentials.
+func (p Project) Redacted() Project {
+	p.Credentials = p.Credentials.Redacted()
+	return p
+}
+
+// Redacted returns g without the secrets of its credentials.
+func (g Group) Redacted() Group {
+	g.Credentials = g.Credentials.Redacted()
+	return g
+}
+
+// Redacted returns w without its secret, which is write-only.
+func (w Webhook) Redacted() Webhook {
+	w.Secret = ""
+	return w
+}
+
+// Redacted returns c without its token and the values of its headers.
+func (c CommitStatus) Redacted() CommitStatus {
+	c.Token = ""
+	if len(c.Headers) > 0 {
+		headers := make(map[string]string)
+		for k := range c.Headers {
+			headers[k] = ""
+		}
+		c.Headers = headers
+	}
+	return c
+}
+
+// Keep fills in the token and the header values that c leaves empty with
+// those of stored. Headers that stay empty are dropped.
+func (c CommitStatus) Keep(stored CommitStatus) CommitStatus {
+	if c.Token == "" {
+		c.Token = stored.Token
+	}
+
+	headers := c.Headers
+	c.Headers = nil
+	for name, value := range headers {
+		if value == "" {
+			value = stored.Headers[name]
+		}
+		if value == "" {
+			continue
+		}
+
+		if c.Headers == nil {
+			c.Headers = make(map[string]string)
+		}
+		c.Headers[name] = value
+	}
+
+	return c
+}
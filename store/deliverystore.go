@@ -0,0 +1,76 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// maxDeliveries is the number of deliveries that are kept, older ones are
+// dropped.
+const maxDeliveries = 1000
+
+type FileDeliveryStore struct {
+	data []Delivery
+}
+
+func NewDeliveryStore() FileDeliveryStore {
+	return FileDeliveryStore{}
+}
+
+func (s *FileDeliveryStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("deliveries.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileDeliveryStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("deliveries.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileDeliveryStore) List() []Delivery {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileDeliveryStore) Add(delivery Delivery) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.data = append(s.data, delivery)
+	if len(s.data) > maxDeliveries {
+		s.data = s.data[len(s.data)-maxDeliveries:]
+	}
+
+	return nil
+}
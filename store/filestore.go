@@ -104,6 +104,7 @@ func (s *FileStore) Update(url Url) error {
 	i := s.indexOf(url.Id)
 	if i != -1 {
 		s.data[i] = url
+		return nil
 	}
 
 	return errors.New("Not found")
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileGroupStore struct {
+	data []Group
+}
+
+func NewGroupStore() FileGroupStore {
+	return FileGroupStore{}
+}
+
+func (s *FileGroupStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("groups.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileGroupStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("groups.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileGroupStore) List() []Group {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileGroupStore) Get(name string) (*Group, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(name)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileGroupStore) Save(group Group) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(group.Name)
+	if i != -1 {
+		s.data[i] = group
+	} else {
+		s.data = append(s.data, group)
+	}
+
+	return nil
+}
+
+func (s *FileGroupStore) Delete(name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(name)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data = append(s.data[:i], s.data[i+1:]...)
+
+	return nil
+}
+
+func (s *FileGroupStore) indexOf(name string) int {
+	for i, item := range s.data {
+		if item.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileProjectStore struct {
+	data []Project
+}
+
+func NewProjectStore() FileProjectStore {
+	return FileProjectStore{}
+}
+
+func (s *FileProjectStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("projects.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileProjectStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("projects.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileProjectStore) List() []Project {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileProjectStore) Get(name string) (*Project, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(name)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileProjectStore) Save(project Project) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(project.Name)
+	if i != -1 {
+		s.data[i] = project
+	} else {
+		s.data = append(s.data, project)
+	}
+
+	return nil
+}
+
+func (s *FileProjectStore) Delete(name string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(name)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data = append(s.data[:i], s.data[i+1:]...)
+
+	return nil
+}
+
+func (s *FileProjectStore) indexOf(name string) int {
+	for i, item := range s.data {
+		if item.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileRunStore struct {
+	data []Run
+}
+
+func NewRunStore() FileRunStore {
+	return FileRunStore{}
+}
+
+func (s *FileRunStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("runs.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileRunStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("runs.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileRunStore) List() []Run {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileRunStore) Get(id int) (*Run, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileRunStore) Add(run Run) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.data = append(s.data, run)
+
+	return nil
+}
+
+func (s *FileRunStore) Update(run Run) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(run.Id)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data[i] = run
+
+	return nil
+}
+
+func (s *FileRunStore) indexOf(id int) int {
+	for i, item := range s.data {
+		if item.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -1,5 +1,12 @@
 package store
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
 type StatusType int
 
 const (
@@ -8,14 +15,375 @@ const (
 	FAIL
 )
 
+type ErrorPolicy string
+
+const (
+	IgnoreErrors ErrorPolicy = ""
+	WarnOnErrors ErrorPolicy = "warning"
+	FailOnErrors ErrorPolicy = "fail"
+)
+
+type EventType string
+
+const (
+	ConsoleEvent   EventType = "console"
+	ExceptionEvent EventType = "exception"
+	NetworkEvent   EventType = "network"
+)
+
+type PageEvent struct {
+	Type    EventType `json:"type"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"`
+	Status  int       `json:"status,omitempty"`
+}
+
+// IsError reports whether the event should count as a page error when
+// comparing a scan against its reference.
+func (e PageEvent) IsError() bool {
+	switch e.Type {
+	case ExceptionEvent, NetworkEvent:
+		return true
+	default:
+		return e.Level == "error" || e.Level == "assert"
+	}
+}
+
+type Rect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type DomNode struct {
+	Parent int               `json:"parent"`
+	Path   string            `json:"path"`
+	Tag    string            `json:"tag"`
+	Id     string            `json:"id,omitempty"`
+	Class  string            `json:"class,omitempty"`
+	Text   string            `json:"text,omitempty"`
+	Bounds Rect              `json:"bounds"`
+	Styles map[string]string `json:"styles,omitempty"`
+}
+
+type DomSnapshot struct {
+	Width  float64   `json:"width"`
+	Height float64   `json:"height"`
+	Text   string    `json:"text"`
+	Nodes  []DomNode `json:"nodes"`
+}
+
+type DomChangeType string
+
+const (
+	ElementAdded   DomChangeType = "added"
+	ElementRemoved DomChangeType = "removed"
+	ElementMoved   DomChangeType = "moved"
+	TextChanged    DomChangeType = "text"
+	StyleChanged   DomChangeType = "style"
+)
+
+type DomChange struct {
+	Type   DomChangeType `json:"type"`
+	Path   string        `json:"path"`
+	Before string        `json:"before,omitempty"`
+	After  string        `json:"after,omitempty"`
+	Bounds Rect          `json:"bounds"`
+}
+
+type Region struct {
+	Bounds   Rect   `json:"bounds"`
+	Pixels   int    `json:"pixels"`
+	Severity string `json:"severity"`
+}
+
+type Shift struct {
+	Axis   string `json:"axis"`
+	At     int    `json:"at"`
+	Offset int    `json:"offset"`
+}
+
+type Score struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Pass      bool    `json:"pass"`
+	Pixels    int     `json:"pixels"`
+	Total     int     `json:"total"`
+}
+
+type Results struct {
+	Summary string `json:"summary"`
+	Score   Score  `json:"score"`
+}
+
+// UnmarshalJSON also reads the results of older stores, which were only the
+// summary as a string.
+func (r *Results) UnmarshalJSON(b []byte) error {
+	var summary string
+	if json.Unmarshal(b, &summary) == nil {
+		*r = Results{Summary: summary}
+		return nil
+	}
+
+	type results Results
+	return json.Unmarshal(b, (*results)(r))
+}
+
+// Limits are the largest differences that are still accepted. Limits that
+// are not set are not checked.
+type Limits struct {
+	MaxPixels  *int     `json:"maxPixels,omitempty" yaml:"maxPixels,omitempty"`
+	MaxPercent *float64 `json:"maxPercent,omitempty" yaml:"maxPercent,omitempty"`
+	MaxScore   *float64 `json:"maxScore,omitempty" yaml:"maxScore,omitempty"`
+}
+
+// Thresholds map a diff to WARNING when it exceeds the warning limits and to
+// FAIL when it exceeds the fail limits.
+type Thresholds struct {
+	Warning *Limits `json:"warning,omitempty" yaml:"warning,omitempty"`
+	Fail    *Limits `json:"fail,omitempty" yaml:"fail,omitempty"`
+}
+
+// Viewport is the window size that a page is captured at.
+type Viewport struct {
+	Width  int     `json:"width" yaml:"width"`
+	Height int     `json:"height" yaml:"height"`
+	Scale  float64 `json:"scale,omitempty" yaml:"scale,omitempty"`
+}
+
+// Mask leaves part of a page out of the comparison, e.g. a clock or an ad.
+// Elements matching Selector are hidden before the capture, Rect (in page
+// pixels) is blanked in both screenshots before they are compared.
+type Mask struct {
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Rect     *Rect  `json:"rect,omitempty" yaml:"rect,omitempty"`
+}
+
+// Credentials are sent with every request of a capture: basic auth when
+// Username is set, and any extra headers.
+type Credentials struct {
+	Username string            `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string            `json:"password,omitempty" yaml:"password,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// Defaults are settings shared by the urls of a project or group. A url's
+// own settings win over those of its group, which win over those of its
+// project. Masks add up.
+type Defaults struct {
+	BaseUrl     string       `json:"baseUrl,omitempty"`
+	Viewport    *Viewport    `json:"viewport,omitempty"`
+	Masks       []Mask       `json:"masks,omitempty"`
+	Thresholds  Thresholds   `json:"thresholds"`
+	Credentials *Credentials `json:"credentials,omitempty"`
+}
+
+// ValidBaseUrl checks that a base url is an absolute http(s) url.
+func ValidBaseUrl(baseUrl string) error {
+	u, err := url.Parse(baseUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base url %q", baseUrl)
+	}
+	return nil
+}
+
+// Project is a set of urls, e.g. one site or environment.
+type Project struct {
+	Name string `json:"name"`
+	Defaults
+}
+
+type Group struct {
+	Name string `json:"name"`
+	// Project is the project the group belongs to, if any.
+	Project string `json:"project,omitempty"`
+	Defaults
+}
+
+// ImageFormat is the format screenshots are captured and stored in.
+type ImageFormat string
+
+const (
+	PNG  ImageFormat = "png"
+	JPEG ImageFormat = "jpeg"
+	WebP ImageFormat = "webp"
+)
+
 type Url struct {
-	Id        int        `json:"id"`
-	Url       string     `json:"url"`
-	Reference string     `json:"reference"`
-	Current   string     `json:"current"`
-	Overlay   string     `json:"overlay"`
-	Results   string     `json:"results"`
-	Status    StatusType `json:"status"`
+	Id              int          `json:"id"`
+	Url             string       `json:"url"`
+	Reference       string       `json:"reference"`
+	Current         string       `json:"current"`
+	Overlay         string       `json:"overlay"`
+	Results         Results      `json:"results"`
+	Regions         []Region     `json:"regions"`
+	Shifts          []Shift      `json:"shifts"`
+	Align           bool         `json:"align"`
+	Metric          string       `json:"metric"`
+	Threshold       float64      `json:"threshold"`
+	Tolerance       uint8        `json:"tolerance"`
+	ColorSpace      string       `json:"colorSpace"`
+	ColorDistance   float64      `json:"colorDistance"`
+	Project         string       `json:"project,omitempty"`
+	Group           string       `json:"group"`
+	Tags            []string     `json:"tags,omitempty"`
+	Viewport        *Viewport    `json:"viewport,omitempty"`
+	Masks           []Mask       `json:"masks,omitempty"`
+	Credentials     *Credentials `json:"credentials,omitempty"`
+	Thresholds      Thresholds   `json:"thresholds"`
+	Format          ImageFormat  `json:"format,omitempty"`
+	Quality         int          `json:"quality,omitempty"`
+	Status          StatusType   `json:"status"`
+	ReferenceEvents []PageEvent  `json:"referenceEvents"`
+	CurrentEvents   []PageEvent  `json:"currentEvents"`
+	ErrorPolicy     ErrorPolicy  `json:"errorPolicy"`
+	ReferenceDom    *DomSnapshot `json:"referenceDom,omitempty"`
+	CurrentDom      *DomSnapshot `json:"currentDom,omitempty"`
+	DomChanges      []DomChange  `json:"domChanges"`
+}
+
+// Mapping rewrites a path for the target of a comparison. Match is a regular
+// expression, Replace may refer to its groups as $1.
+type Mapping struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+type ComparisonState string
+
+const (
+	ComparisonRunning  ComparisonState = "running"
+	ComparisonFinished ComparisonState = "finished"
+	// ComparisonFailed comparisons stopped before all their urls were
+	// compared, Error tells why.
+	ComparisonFailed ComparisonState = "failed"
+)
+
+// ComparisonResult is one path of a comparison. Url holds the diff the way a
+// stored url does, with the capture of the base as its reference and that
+// of the target as its current screenshot.
+type ComparisonResult struct {
+	Base   string `json:"base"`
+	Target string `json:"target"`
+	Error  string `json:"error,omitempty"`
+	Url    Url    `json:"url"`
+}
+
+// Comparison captures the same paths on two deployments, e.g. production and
+// staging, and diffs them with each other.
+type Comparison struct {
+	Id       int       `json:"id"`
+	Base     string    `json:"base"`
+	Target   string    `json:"target"`
+	Mappings []Mapping `json:"mappings"`
+	// Paths are compared with default settings, the stored urls that match
+	// Project, Group and Tag with their own.
+	Paths    []string           `json:"paths,omitempty"`
+	Project  string             `json:"project,omitempty"`
+	Group    string             `json:"group,omitempty"`
+	Tag      string             `json:"tag,omitempty"`
+	State    ComparisonState    `json:"state"`
+	Status   StatusType         `json:"status"`
+	Total    int                `json:"total"`
+	Done     int                `json:"done"`
+	Created  time.Time          `json:"created"`
+	Finished *time.Time         `json:"finished,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Results  []ComparisonResult `json:"results"`
+}
+
+type RunState string
+
+const (
+	RunRunning  RunState = "running"
+	RunFinished RunState = "finished"
+)
+
+// RunResult is one url of a run, as it was when its scan finished. Urls
+// that are still being scanned are not Done.
+type RunResult struct {
+	Id      int        `json:"id"`
+	Url     string     `json:"url"`
+	Done    bool       `json:"done"`
+	Status  StatusType `json:"status"`
+	Results Results    `json:"results"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// Run ties together the scans that were started at the same time, e.g. by a
+// CI job for a commit. Status is the worst status of its urls, Link is where
+// the run can be fetched.
+type Run struct {
+	Id          int               `json:"id"`
+	Type        string            `json:"type"`
+	Branch      string            `json:"branch,omitempty"`
+	Commit      string            `json:"commit,omitempty"`
+	TriggeredBy string            `json:"triggeredBy,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	BaseUrl     string            `json:"baseUrl,omitempty"`
+	Link        string            `json:"link,omitempty"`
+	Project     string            `json:"project,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Tag         string            `json:"tag,omitempty"`
+	State       RunState          `json:"state"`
+	Status      StatusType        `json:"status"`
+	Total       int               `json:"total"`
+	Done        int               `json:"done"`
+	Failed      int               `json:"failed"`
+	Created     time.Time         `json:"created"`
+	Finished    *time.Time        `json:"finished,omitempty"`
+	Results     []RunResult       `json:"results"`
+}
+
+// Webhook posts events to Url. Events are the types of events it receives,
+// all of them when empty. Template renders the JSON body, the event itself
+// is sent without one. With a Secret the body is signed.
+type Webhook struct {
+	Id       int      `json:"id"`
+	Url      string   `json:"url"`
+	Events   []string `json:"events"`
+	Template string   `json:"template,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+}
+
+// Delivery is one attempt to deliver an event to a webhook. Attempts to
+// deliver the same event share the Id. Duration is in seconds.
+type Delivery struct {
+	Id         string    `json:"id"`
+	Webhook    int       `json:"webhook"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	Time       time.Time `json:"time"`
+	Duration   float64   `json:"duration"`
+}
+
+// CommitStatus posts the outcome of runs for a commit as a commit status,
+// with the "http" or the "github" provider. Project limits it to the runs
+// of a project. TargetUrl is a template of the link in the status, the run
+// by default.
+type CommitStatus struct {
+	Id        int    `json:"id"`
+	Type      string `json:"type"`
+	Project   string `json:"project,omitempty"`
+	Context   string `json:"context,omitempty"`
+	TargetUrl string `json:"targetUrl,omitempty"`
+	// Url, Headers and Body are templates of the request of the http
+	// provider.
+	Url     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// ApiUrl, Repository ("owner/name") and Token are for the github
+	// provider.
+	ApiUrl     string `json:"apiUrl,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Token      string `json:"token,omitempty"`
 }
 
 type Store interface {
@@ -29,6 +397,74 @@ type Store interface {
 	Delete(id int) error
 }
 
+type GroupStore interface {
+	Open() error
+	Close()
+
+	List() []Group
+	Get(name string) (*Group, error)
+	Save(group Group) error
+	Delete(name string) error
+}
+
+type ProjectStore interface {
+	Open() error
+	Close()
+
+	List() []Project
+	Get(name string) (*Project, error)
+	Save(project Project) error
+	Delete(name string) error
+}
+
+type ComparisonStore interface {
+	Open() error
+	Close()
+
+	List() []Comparison
+	Get(id int) (*Comparison, error)
+	Add(comparison Comparison) error
+	Update(comparison Comparison) error
+}
+
+type RunStore interface {
+	Open() error
+	Close()
+
+	List() []Run
+	Get(id int) (*Run, error)
+	Add(run Run) error
+	Update(run Run) error
+}
+
+type WebhookStore interface {
+	Open() error
+	Close()
+
+	List() []Webhook
+	Get(id int) (*Webhook, error)
+	Save(webhook Webhook) error
+	Delete(id int) error
+}
+
+type DeliveryStore interface {
+	Open() error
+	Close()
+
+	List() []Delivery
+	Add(delivery Delivery) error
+}
+
+type CommitStatusStore interface {
+	Open() error
+	Close()
+
+	List() []CommitStatus
+	Get(id int) (*CommitStatus, error)
+	Save(status CommitStatus) error
+	Delete(id int) error
+}
+
 type HandlerError struct {
 	Message string
 	Code    int
@@ -37,3 +473,11 @@ type HandlerError struct {
 func (h HandlerError) Error() string {
 	return h.Message
 }
+
+// RawResponse is written to the client as it is, instead of as JSON. With a
+// Filename it is sent as a download.
+type RawResponse struct {
+	ContentType string
+	Filename    string
+	Data        []byte
+}
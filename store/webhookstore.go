@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+)
+
+type FileWebhookStore struct {
+	data []Webhook
+}
+
+func NewWebhookStore() FileWebhookStore {
+	return FileWebhookStore{}
+}
+
+func (s *FileWebhookStore) Open() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	f, err := os.Open("webhooks.json")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byteValue, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, &s.data)
+}
+
+func (s *FileWebhookStore) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	outfile, err := os.Create("webhooks.json")
+	if err != nil {
+		panic(err)
+	}
+	defer outfile.Close()
+	outfile.Write(b)
+}
+
+func (s *FileWebhookStore) List() []Webhook {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.data
+}
+
+func (s *FileWebhookStore) Get(id int) (*Webhook, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i != -1 {
+		return &s.data[i], nil
+	}
+
+	return nil, errors.New("Not found")
+}
+
+func (s *FileWebhookStore) Save(webhook Webhook) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(webhook.Id)
+	if i != -1 {
+		s.data[i] = webhook
+	} else {
+		s.data = append(s.data, webhook)
+	}
+
+	return nil
+}
+
+func (s *FileWebhookStore) Delete(id int) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i := s.indexOf(id)
+	if i == -1 {
+		return errors.New("Not found")
+	}
+
+	s.data = append(s.data[:i], s.data[i+1:]...)
+
+	return nil
+}
+
+func (s *FileWebhookStore) indexOf(id int) int {
+	for i, item := range s.data {
+		if item.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
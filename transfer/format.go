@@ -0,0 +1,338 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+var contentTypes = map[string]string{
+	"json": "application/json",
+	"yaml": "application/x-yaml",
+	"csv":  "text/csv",
+}
+
+// ContentType returns the media type of an export format, or "" for an
+// unknown format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Encode writes the settings as json, yaml or csv.
+func Encode(w io.Writer, format string, settings []Settings) error {
+	if settings == nil {
+		settings = []Settings{}
+	}
+
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case "yaml":
+		b, err := yaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "csv":
+		return encodeCSV(w, settings)
+	default:
+		return fmt.Errorf("unknown format %q, use json, yaml or csv", format)
+	}
+}
+
+// Decode reads settings written by Encode. CSV columns may be in any order
+// and may be left out.
+func Decode(r io.Reader, format string) ([]Settings, error) {
+	var settings []Settings
+
+	switch format {
+	case "json":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &settings); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.UnmarshalStrict(data, &settings); err != nil {
+			return nil, err
+		}
+	case "csv":
+		var err error
+		if settings, err = decodeCSV(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q, use json, yaml or csv", format)
+	}
+
+	for i, s := range settings {
+		if s.Url == "" {
+			return nil, fmt.Errorf("url %d has no url", i+1)
+		}
+	}
+
+	return settings, nil
+}
+
+// column is a csv column, with the thresholds flattened to warning.maxPixels
+// and so on.
+type column struct {
+	name string
+	get  func(s *Settings) string
+	set  func(s *Settings, v string) error
+}
+
+var columns = []column{
+	{"id", func(s *Settings) string { return formatInt(s.Id) }, func(s *Settings, v string) error { return parseInt(v, &s.Id) }},
+	{"url", func(s *Settings) string { return s.Url }, func(s *Settings, v string) error { s.Url = v; return nil }},
+	{"project", func(s *Settings) string { return s.Project }, func(s *Settings, v string) error { s.Project = v; return nil }},
+	{"group", func(s *Settings) string { return s.Group }, func(s *Settings, v string) error { s.Group = v; return nil }},
+	{"tags", func(s *Settings) string { return strings.Join(s.Tags, ";") }, func(s *Settings, v string) error { s.Tags = strings.Split(v, ";"); return nil }},
+	{"viewport", func(s *Settings) string { return formatViewport(s.Viewport) }, func(s *Settings, v string) error { return parseViewport(v, &s.Viewport) }},
+	// Masks and credentials don't fit in a column, they are stored as json.
+	{"masks", func(s *Settings) string { return formatJSON(s.Masks, len(s.Masks) == 0) }, func(s *Settings, v string) error { return json.Unmarshal([]byte(v), &s.Masks) }},
+	{"credentials", func(s *Settings) string { return formatJSON(s.Credentials, s.Credentials == nil) }, func(s *Settings, v string) error { return json.Unmarshal([]byte(v), &s.Credentials) }},
+	{"align", func(s *Settings) string { return formatBool(s.Align) }, func(s *Settings, v string) error { return parseBool(v, &s.Align) }},
+	{"metric", func(s *Settings) string { return s.Metric }, func(s *Settings, v string) error { s.Metric = v; return nil }},
+	{"threshold", func(s *Settings) string { return formatFloat(s.Threshold) }, func(s *Settings, v string) error { return parseFloat(v, &s.Threshold) }},
+	{"tolerance", func(s *Settings) string { return formatInt(int(s.Tolerance)) }, func(s *Settings, v string) error {
+		var t int
+		if err := parseInt(v, &t); err != nil {
+			return err
+		}
+		if t < 0 || t > 255 {
+			return fmt.Errorf("tolerance %d out of range", t)
+		}
+		s.Tolerance = uint8(t)
+		return nil
+	}},
+	{"colorSpace", func(s *Settings) string { return s.ColorSpace }, func(s *Settings, v string) error { s.ColorSpace = v; return nil }},
+	{"colorDistance", func(s *Settings) string { return formatFloat(s.ColorDistance) }, func(s *Settings, v string) error { return parseFloat(v, &s.ColorDistance) }},
+	{"format", func(s *Settings) string { return string(s.Format) }, func(s *Settings, v string) error { s.Format = store.ImageFormat(v); return nil }},
+	{"quality", func(s *Settings) string { return formatInt(s.Quality) }, func(s *Settings, v string) error { return parseInt(v, &s.Quality) }},
+	{"errorPolicy", func(s *Settings) string { return string(s.ErrorPolicy) }, func(s *Settings, v string) error { s.ErrorPolicy = store.ErrorPolicy(v); return nil }},
+}
+
+func init() {
+	levels := []struct {
+		name   string
+		limits func(s *Settings) **store.Limits
+	}{
+		{"warning", func(s *Settings) **store.Limits { return &s.Thresholds.Warning }},
+		{"fail", func(s *Settings) **store.Limits { return &s.Thresholds.Fail }},
+	}
+
+	for _, level := range levels {
+		limits := level.limits
+		get := func(s *Settings) *store.Limits {
+			if l := *limits(s); l != nil {
+				return l
+			}
+			return &store.Limits{}
+		}
+		set := func(s *Settings) *store.Limits {
+			if *limits(s) == nil {
+				*limits(s) = &store.Limits{}
+			}
+			return *limits(s)
+		}
+
+		columns = append(columns,
+			column{level.name + ".maxPixels",
+				func(s *Settings) string { return formatIntPtr(get(s).MaxPixels) },
+				func(s *Settings, v string) error { return parseIntPtr(v, &set(s).MaxPixels) }},
+			column{level.name + ".maxPercent",
+				func(s *Settings) string { return formatFloatPtr(get(s).MaxPercent) },
+				func(s *Settings, v string) error { return parseFloatPtr(v, &set(s).MaxPercent) }},
+			column{level.name + ".maxScore",
+				func(s *Settings) string { return formatFloatPtr(get(s).MaxScore) },
+				func(s *Settings, v string) error { return parseFloatPtr(v, &set(s).MaxScore) }},
+		)
+	}
+}
+
+func encodeCSV(w io.Writer, settings []Settings) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	cw.Write(header)
+
+	for _, s := range settings {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = c.get(&s)
+		}
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func decodeCSV(r io.Reader) ([]Settings, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	byName := make(map[string]column)
+	for _, c := range columns {
+		byName[strings.ToLower(c.name)] = c
+	}
+
+	header := make([]column, len(records[0]))
+	for i, name := range records[0] {
+		c, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		header[i] = c
+	}
+
+	var settings []Settings
+	for n, record := range records[1:] {
+		var s Settings
+		for i, v := range record {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			if err := header[i].set(&s, v); err != nil {
+				return nil, fmt.Errorf("line %d, %v: %v", n+2, header[i].name, err)
+			}
+		}
+		settings = append(settings, s)
+	}
+
+	return settings, nil
+}
+
+// formatViewport writes a viewport as 1024x768, or 1024x768@2 with a scale.
+func formatViewport(v *store.Viewport) string {
+	if v == nil {
+		return ""
+	}
+	if v.Scale != 0 && v.Scale != 1 {
+		return fmt.Sprintf("%dx%d@%s", v.Width, v.Height, strconv.FormatFloat(v.Scale, 'g', -1, 64))
+	}
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+func parseViewport(s string, v **store.Viewport) error {
+	var vp store.Viewport
+
+	size := s
+	if i := strings.IndexByte(s, '@'); i >= 0 {
+		size = s[:i]
+		if err := parseFloat(s[i+1:], &vp.Scale); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Sscanf(size, "%dx%d", &vp.Width, &vp.Height); err != nil {
+		return fmt.Errorf("invalid viewport %q, use 1024x768 or 1024x768@2", s)
+	}
+
+	*v = &vp
+	return nil
+}
+
+func formatJSON(v interface{}, empty bool) string {
+	if empty {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func formatInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func formatBool(b bool) string {
+	if !b {
+		return ""
+	}
+	return "true"
+}
+
+func formatIntPtr(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func formatFloatPtr(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'g', -1, 64)
+}
+
+func parseInt(v string, i *int) (err error) {
+	*i, err = strconv.Atoi(v)
+	return err
+}
+
+func parseFloat(v string, f *float64) (err error) {
+	*f, err = strconv.ParseFloat(v, 64)
+	return err
+}
+
+func parseBool(v string, b *bool) (err error) {
+	*b, err = strconv.ParseBool(v)
+	return err
+}
+
+func parseIntPtr(v string, i **int) error {
+	var n int
+	if err := parseInt(v, &n); err != nil {
+		return err
+	}
+	*i = &n
+	return nil
+}
+
+func parseFloatPtr(v string, f **float64) error {
+	var n float64
+	if err := parseFloat(v, &n); err != nil {
+		return err
+	}
+	*f = &n
+	return nil
+}
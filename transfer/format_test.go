@@ -0,0 +1,100 @@
+package transfer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jvdanker/mug/store"
+)
+
+func testUrls() []store.Url {
+	pixels, percent := 10, 0.5
+
+	return []store.Url{
+		{Id: 1, Url: "https://www.example.com/", Project: "site", Metric: "antialias", Tolerance: 8},
+		{
+			Id:         2,
+			Url:        "/products",
+			Project:    "site",
+			Group:      "shop",
+			Tags:       []string{"smoke", "shop"},
+			Viewport:   &store.Viewport{Width: 375, Height: 667, Scale: 2},
+			Masks:      []store.Mask{{Selector: ".ad"}, {Rect: &store.Rect{X: 1, Y: 2, Width: 3, Height: 4}}},
+			Thresholds: store.Thresholds{Warning: &store.Limits{MaxPixels: &pixels}, Fail: &store.Limits{MaxPercent: &percent}},
+			Format:     store.JPEG,
+			Quality:    80,
+		},
+		// Empty lists and limits are written like missing ones.
+		{Id: 3, Url: "/about", Tags: []string{}, Masks: []store.Mask{}, Thresholds: store.Thresholds{Warning: &store.Limits{}}},
+		{Id: 4, Url: "/account", Credentials: &store.Credentials{Username: "user", Password: "secret", Headers: map[string]string{"X-Token": "abc"}}},
+		{Id: 5, Url: "/empty", Credentials: &store.Credentials{}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	urls := testUrls()
+
+	for _, format := range []string{"json", "yaml", "csv"} {
+		var settings []Settings
+		for _, u := range urls {
+			settings = append(settings, FromUrl(u.Redacted()))
+		}
+
+		buf := new(bytes.Buffer)
+		if err := Encode(buf, format, settings); err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+
+		imported, err := Decode(buf, format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+
+		changes, err := Plan(urls, imported, Replace)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if len(changes) != len(urls) {
+			t.Errorf("%v: %d changes for %d urls", format, len(changes), len(urls))
+		}
+		for _, c := range changes {
+			if c.Action != Unchanged {
+				t.Errorf("%v: %v %v %v", format, c.Action, c.Url, c.Fields)
+			}
+		}
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	data := "URL, tags ,viewport,warning.maxPercent,fail.maxPixels\n" +
+		"/a,x;y,1024x768@2,0.5,\n" +
+		"/b,,,,\n"
+
+	settings, err := Decode(strings.NewReader(data), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 2 {
+		t.Fatalf("%d settings", len(settings))
+	}
+
+	a := settings[0]
+	if a.Url != "/a" || len(a.Tags) != 2 || a.Viewport == nil || a.Viewport.Scale != 2 {
+		t.Errorf("got %+v", a)
+	}
+	if a.Thresholds.Warning == nil || *a.Thresholds.Warning.MaxPercent != 0.5 || a.Thresholds.Fail != nil {
+		t.Errorf("thresholds %+v", a.Thresholds)
+	}
+
+	b := settings[1]
+	if b.Tags != nil || b.Viewport != nil || b.Thresholds.Warning != nil {
+		t.Errorf("empty cells: got %+v", b)
+	}
+
+	for _, data := range []string{"url,bogus\n/a,1\n", "url,quality\n/a,high\n", "url,tolerance\n/a,300\n", "tags\nx\n"} {
+		if _, err = Decode(strings.NewReader(data), "csv"); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package transfer
+
+import (
+	"fmt"
+	"github.com/jvdanker/mug/store"
+)
+
+type Mode string
+
+const (
+	// Merge adds new urls and updates existing ones, urls that are not
+	// imported stay.
+	Merge Mode = "merge"
+	// Replace also deletes the urls that are not imported.
+	Replace Mode = "replace"
+)
+
+type Action string
+
+const (
+	Add       Action = "add"
+	Update    Action = "update"
+	Delete    Action = "delete"
+	Unchanged Action = "unchanged"
+)
+
+// Change is what an import does to one url. Imported urls are matched with
+// stored ones by their project, group and url, ids differ between
+// environments.
+type Change struct {
+	Action Action `json:"action"`
+	// Id of the stored url, 0 for added urls.
+	Id  int    `json:"id,omitempty"`
+	Url string `json:"url"`
+	// Fields lists the settings that an update changes.
+	Fields   []string  `json:"fields,omitempty"`
+	Settings *Settings `json:"-"`
+}
+
+// key identifies a url in an import, the same url can be in several
+// projects or groups.
+type key struct {
+	project, group, url string
+}
+
+// Plan works out the changes of importing settings into the stored urls.
+func Plan(existing []store.Url, imported []Settings, mode Mode) ([]Change, error) {
+	if mode == "" {
+		mode = Merge
+	}
+	if mode != Merge && mode != Replace {
+		return nil, fmt.Errorf("unknown mode %q, use merge or replace", mode)
+	}
+
+	stored := make(map[key]store.Url)
+	for _, u := range existing {
+		stored[key{u.Project, u.Group, u.Url}] = u
+	}
+
+	var changes []Change
+	seen := make(map[key]bool)
+	for i := range imported {
+		s := imported[i]
+		k := key{s.Project, s.Group, s.Url}
+		if seen[k] {
+			return nil, fmt.Errorf("%v is imported twice", describe(k))
+		}
+		seen[k] = true
+
+		u, ok := stored[k]
+		if !ok {
+			changes = append(changes, Change{Action: Add, Url: s.Url, Settings: &s})
+			continue
+		}
+
+		// Exports leave the secrets of credentials out, keep the stored
+		// ones.
+		s.Credentials = s.Credentials.Keep(u.Credentials)
+
+		fields := Changed(FromUrl(u), s)
+		if len(fields) == 0 {
+			changes = append(changes, Change{Action: Unchanged, Id: u.Id, Url: s.Url})
+			continue
+		}
+
+		changes = append(changes, Change{Action: Update, Id: u.Id, Url: s.Url, Fields: fields, Settings: &s})
+	}
+
+	if mode == Replace {
+		for _, u := range existing {
+			if !seen[key{u.Project, u.Group, u.Url}] {
+				changes = append(changes, Change{Action: Delete, Id: u.Id, Url: u.Url})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+func describe(k key) string {
+	s := k.url
+	if k.project != "" {
+		s += " of project " + k.project
+	}
+	if k.group != "" {
+		s += " in group " + k.group
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package transfer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jvdanker/mug/store"
+)
+
+func TestPlan(t *testing.T) {
+	existing := []store.Url{
+		{Id: 1, Url: "/a", Project: "site", Metric: "exact"},
+		{Id: 2, Url: "/a", Project: "staging", Metric: "exact"},
+		{Id: 3, Url: "/b", Project: "site"},
+	}
+	imported := []Settings{
+		{Url: "/a", Project: "site", Metric: "exact"},
+		{Url: "/a", Project: "staging", Metric: "ssim", Tags: []string{"x"}},
+		{Url: "/a", Project: "site", Group: "new"},
+	}
+
+	type change struct {
+		action Action
+		id     int
+		fields []string
+	}
+	for mode, want := range map[Mode][]change{
+		Merge: {
+			{Unchanged, 1, nil},
+			{Update, 2, []string{"tags", "metric"}},
+			{Add, 0, nil},
+		},
+		Replace: {
+			{Unchanged, 1, nil},
+			{Update, 2, []string{"tags", "metric"}},
+			{Add, 0, nil},
+			{Delete, 3, nil},
+		},
+	} {
+		changes, err := Plan(existing, imported, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []change
+		for _, c := range changes {
+			got = append(got, change{c.Action, c.Id, c.Fields})
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	twice := []Settings{{Url: "/a", Project: "site"}, {Url: "/a", Project: "site"}}
+	if _, err := Plan(nil, twice, Merge); err == nil {
+		t.Error("url imported twice: no error")
+	}
+
+	if _, err := Plan(nil, nil, "mirror"); err == nil {
+		t.Error("unknown mode: no error")
+	}
+}
+
+func TestPlanKeepsCredentials(t *testing.T) {
+	existing := []store.Url{{Id: 1, Url: "/a", Credentials: &store.Credentials{Username: "user", Password: "secret"}}}
+
+	changes, err := Plan(existing, []Settings{{Url: "/a", Credentials: &store.Credentials{Username: "user"}}}, Merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes[0].Action != Unchanged {
+		t.Errorf("redacted password: %v %v", changes[0].Action, changes[0].Fields)
+	}
+
+	changes, _ = Plan(existing, []Settings{{Url: "/a", Credentials: &store.Credentials{Username: "other"}}}, Merge)
+	if changes[0].Action != Update || changes[0].Settings.Credentials.Password != "" {
+		t.Errorf("other user: %v %+v", changes[0].Action, changes[0].Settings.Credentials)
+	}
+}
@@ -0,0 +1,128 @@
+package transfer
+
+import (
+	"github.com/jvdanker/mug/store"
+	"reflect"
+	"strings"
+)
+
+// Settings are the fields of a url that move between environments: the url
+// and how it is captured and compared, without screenshots or results.
+type Settings struct {
+	Id            int                `json:"id,omitempty" yaml:"id,omitempty"`
+	Url           string             `json:"url" yaml:"url"`
+	Project       string             `json:"project,omitempty" yaml:"project,omitempty"`
+	Group         string             `json:"group,omitempty" yaml:"group,omitempty"`
+	Tags          []string           `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Align         bool               `json:"align,omitempty" yaml:"align,omitempty"`
+	Metric        string             `json:"metric,omitempty" yaml:"metric,omitempty"`
+	Threshold     float64            `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Tolerance     uint8              `json:"tolerance,omitempty" yaml:"tolerance,omitempty"`
+	ColorSpace    string             `json:"colorSpace,omitempty" yaml:"colorSpace,omitempty"`
+	ColorDistance float64            `json:"colorDistance,omitempty" yaml:"colorDistance,omitempty"`
+	Format        store.ImageFormat  `json:"format,omitempty" yaml:"format,omitempty"`
+	Quality       int                `json:"quality,omitempty" yaml:"quality,omitempty"`
+	ErrorPolicy   store.ErrorPolicy  `json:"errorPolicy,omitempty" yaml:"errorPolicy,omitempty"`
+	Thresholds    store.Thresholds   `json:"thresholds" yaml:"thresholds,omitempty"`
+	Viewport      *store.Viewport    `json:"viewport,omitempty" yaml:"viewport,omitempty"`
+	Masks         []store.Mask       `json:"masks,omitempty" yaml:"masks,omitempty"`
+	Credentials   *store.Credentials `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+func FromUrl(u store.Url) Settings {
+	return Settings{
+		Id:            u.Id,
+		Url:           u.Url,
+		Project:       u.Project,
+		Group:         u.Group,
+		Tags:          u.Tags,
+		Align:         u.Align,
+		Metric:        u.Metric,
+		Threshold:     u.Threshold,
+		Tolerance:     u.Tolerance,
+		ColorSpace:    u.ColorSpace,
+		ColorDistance: u.ColorDistance,
+		Format:        u.Format,
+		Quality:       u.Quality,
+		ErrorPolicy:   u.ErrorPolicy,
+		Thresholds:    u.Thresholds,
+		Viewport:      u.Viewport,
+		Masks:         u.Masks,
+		Credentials:   u.Credentials,
+	}
+}
+
+// Apply sets the settings on a url, keeping its id and results.
+func (s Settings) Apply(u *store.Url) {
+	u.Url = s.Url
+	u.Project = s.Project
+	u.Group = s.Group
+	u.Tags = s.Tags
+	u.Align = s.Align
+	u.Metric = s.Metric
+	u.Threshold = s.Threshold
+	u.Tolerance = s.Tolerance
+	u.ColorSpace = s.ColorSpace
+	u.ColorDistance = s.ColorDistance
+	u.Format = s.Format
+	u.Quality = s.Quality
+	u.ErrorPolicy = s.ErrorPolicy
+	u.Thresholds = s.Thresholds
+	u.Viewport = s.Viewport
+	u.Masks = s.Masks
+	u.Credentials = s.Credentials
+}
+
+// ToUrl returns a new url with the settings.
+func (s Settings) ToUrl() store.Url {
+	var u store.Url
+	s.Apply(&u)
+	return u
+}
+
+// Changed returns the json names of the fields that differ, the id is not
+// compared. Empty lists, limits and credentials are the same as none, the
+// formats don't tell them apart.
+func Changed(a, b Settings) []string {
+	va, vb := reflect.ValueOf(normalize(a)), reflect.ValueOf(normalize(b))
+	t := va.Type()
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name == "Id" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			fields = append(fields, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+		}
+	}
+
+	return fields
+}
+
+func normalize(s Settings) Settings {
+	if len(s.Tags) == 0 {
+		s.Tags = nil
+	}
+	if len(s.Masks) == 0 {
+		s.Masks = nil
+	}
+
+	empty := store.Limits{}
+	if l := s.Thresholds.Warning; l != nil && *l == empty {
+		s.Thresholds.Warning = nil
+	}
+	if l := s.Thresholds.Fail; l != nil && *l == empty {
+		s.Thresholds.Fail = nil
+	}
+
+	if c := s.Credentials; c != nil {
+		if c.Username == "" && c.Password == "" && len(c.Headers) == 0 {
+			s.Credentials = nil
+		} else if c.Headers != nil && len(c.Headers) == 0 {
+			s.Credentials = &store.Credentials{Username: c.Username, Password: c.Password}
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jvdanker/mug/store"
+	"text/template"
+	"time"
+)
+
+// The events a webhook can subscribe to.
+const (
+	ReferenceUpdated = "referenceUpdated"
+	CurrentUpdated   = "currentUpdated"
+	DiffUpdated      = "diffUpdated"
+	RunFinished      = "runFinished"
+	Failure          = "failure"
+)
+
+var Events = []string{ReferenceUpdated, CurrentUpdated, DiffUpdated, RunFinished, Failure}
+
+// Url is the url an event is about, without its screenshots. Run is the id
+// of the run the scan is part of, if any.
+type Url struct {
+	Id      int              `json:"id"`
+	Url     string           `json:"url"`
+	Status  store.StatusType `json:"status"`
+	Results store.Results    `json:"results"`
+	Error   string           `json:"error,omitempty"`
+	Run     int              `json:"run,omitempty"`
+}
+
+// Event is what a webhook receives. Url is set for the events of a url,
+// Run for RunFinished.
+type Event struct {
+	Type string     `json:"type"`
+	Time time.Time  `json:"time"`
+	Url  *Url       `json:"url,omitempty"`
+	Run  *store.Run `json:"run,omitempty"`
+}
+
+func NewEvent(t string) Event {
+	return Event{
+		Type: t,
+		Time: time.Now(),
+	}
+}
+
+// Valid checks the types of events of a subscription.
+func Valid(events []string) error {
+	for _, e := range events {
+		if !contains(Events, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+
+	return nil
+}
+
+// Matches tells if a subscription to events receives e.
+func Matches(events []string, e Event) bool {
+	return len(events) == 0 || contains(events, e.Type)
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"status": func(s store.StatusType) string {
+		switch s {
+		case store.WARNING:
+			return "warning"
+		case store.FAIL:
+			return "fail"
+		default:
+			return "success"
+		}
+	},
+}
+
+// Render returns the body for e: the event as JSON, or the output of tmpl
+// when it is set. tmpl is a text/template of the event, with json to quote
+// a value and status to name a status. Its output must be JSON, e.g.
+//
+//	{"text": {{json (printf "%s %s" .Type .Url.Url)}}}
+func Render(tmpl string, e Event) ([]byte, error) {
+	if tmpl == "" {
+		return json.Marshal(e)
+	}
+
+	t, err := template.New("payload").Funcs(funcs).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = t.Execute(buf, e); err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("payload template does not render JSON")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Sample returns an example event of type t, for checking templates and
+// testing webhooks.
+func Sample(t string) Event {
+	e := NewEvent(t)
+	if t == RunFinished {
+		e.Run = &store.Run{Id: 1, Type: "current", State: store.RunFinished, Status: store.FAIL, Total: 1, Done: 1, Failed: 1, Created: e.Time, Finished: &e.Time}
+	} else {
+		e.Url = &Url{Id: 1, Url: "https://www.example.com/", Status: store.FAIL, Run: 1}
+	}
+
+	return e
+}
+
+// Sign returns the signature of body: the hex encoded HMAC-SHA256 with the
+// secret as key, prefixed with "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
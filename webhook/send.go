@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Attempt is the outcome of one try to deliver a body.
+type Attempt struct {
+	// Id is the same for every attempt to deliver the same body.
+	Id         string
+	Attempt    int
+	StatusCode int
+	Err        error
+	Time       time.Time
+	Duration   time.Duration
+}
+
+func (a Attempt) Success() bool {
+	return a.Err == nil && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// retry tells if a failed attempt may succeed when it is tried again: it got
+// no response, a server error, a timeout or a rate limit.
+func (a Attempt) retry() bool {
+	if a.StatusCode == 0 || a.StatusCode >= 500 {
+		return true
+	}
+	return a.StatusCode == http.StatusRequestTimeout || a.StatusCode == http.StatusTooManyRequests
+}
+
+// Sender posts bodies to webhooks. A failed delivery is tried up to
+// Attempts times, waiting Backoff before the second attempt and twice as
+// long before every next one.
+type Sender struct {
+	Client   *http.Client
+	Attempts int
+	Backoff  time.Duration
+}
+
+var DefaultSender = Sender{
+	Client:   &http.Client{Timeout: 10 * time.Second},
+	Attempts: 5,
+	Backoff:  time.Second,
+}
+
+// Send posts body to url until it is accepted or the attempts run out. The
+// request carries the event type in X-Mug-Event, the delivery id in
+// X-Mug-Delivery and, with a secret, the signature of the body in
+// X-Mug-Signature-256. Every attempt is passed to log. Send returns the last
+// attempt.
+func (s Sender) Send(url, secret, event string, body []byte, log func(Attempt)) Attempt {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	id := newId()
+	backoff := s.Backoff
+
+	var a Attempt
+	for i := 1; ; i++ {
+		a = s.attempt(client, url, secret, event, id, body)
+		a.Attempt = i
+		if log != nil {
+			log(a)
+		}
+
+		if a.Success() || !a.retry() || i >= s.Attempts {
+			return a
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s Sender) attempt(client *http.Client, url, secret, event, id string, body []byte) Attempt {
+	a := Attempt{
+		Id:   id,
+		Time: time.Now(),
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		a.Err = err
+		return a
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mug-webhook")
+	req.Header.Set("X-Mug-Event", event)
+	req.Header.Set("X-Mug-Delivery", id)
+	if secret != "" {
+		req.Header.Set("X-Mug-Signature-256", Sign(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	a.Duration = time.Since(a.Time)
+	if err != nil {
+		a.Err = err
+		return a
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	a.StatusCode = resp.StatusCode
+	if !a.Success() {
+		a.Err = fmt.Errorf("%v responded %v", url, resp.Status)
+	}
+
+	return a
+}
+
+func newId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jvdanker/mug/store"
+)
+
+// server answers with the status codes in order, repeating the last one,
+// and counts the requests.
+func server(codes ...int) (*httptest.Server, *int) {
+	n := new(int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := codes[len(codes)-1]
+		if *n < len(codes) {
+			code = codes[*n]
+		}
+		*n++
+		w.WriteHeader(code)
+	}))
+	return srv, n
+}
+
+func testSender(srv *httptest.Server) Sender {
+	return Sender{
+		Client:   srv.Client(),
+		Attempts: 3,
+		Backoff:  10 * time.Millisecond,
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	for _, c := range []struct {
+		codes    []int
+		attempts int
+		success  bool
+	}{
+		{[]int{200}, 1, true},
+		{[]int{500, 204}, 2, true},
+		{[]int{429, 503, 200}, 3, true},
+		{[]int{502}, 3, false},
+		{[]int{400}, 1, false},
+		{[]int{404, 200}, 1, false},
+	} {
+		srv, n := server(c.codes...)
+
+		var logged []Attempt
+		a := testSender(srv).Send(srv.URL, "", DiffUpdated, []byte("{}"), func(a Attempt) {
+			logged = append(logged, a)
+		})
+		srv.Close()
+
+		if *n != c.attempts || len(logged) != c.attempts || a.Attempt != c.attempts {
+			t.Errorf("%v: %d requests, %d logged, last attempt %d, want %d", c.codes, *n, len(logged), a.Attempt, c.attempts)
+		}
+		if a.Success() != c.success {
+			t.Errorf("%v: success %v, want %v", c.codes, a.Success(), c.success)
+		}
+		for i, l := range logged {
+			if l.Id != a.Id || l.Attempt != i+1 {
+				t.Errorf("%v: attempt %d logged as %q %d", c.codes, i+1, l.Id, l.Attempt)
+			}
+		}
+	}
+}
+
+func TestSendBackoff(t *testing.T) {
+	srv, _ := server(500)
+	defer srv.Close()
+
+	s := testSender(srv)
+	start := time.Now()
+	s.Send(srv.URL, "", DiffUpdated, []byte("{}"), nil)
+
+	// 10ms before the second attempt and 20ms before the third.
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("3 attempts took %v, want at least 30ms", d)
+	}
+}
+
+func TestSendSignature(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	a := testSender(srv).Send(srv.URL, "s3cret", RunFinished, []byte(`{"type":"runFinished"}`), nil)
+	if !a.Success() {
+		t.Fatal(a.Err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := header.Get("X-Mug-Signature-256"); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if header.Get("X-Mug-Event") != RunFinished || header.Get("X-Mug-Delivery") != a.Id {
+		t.Errorf("event %q, delivery %q", header.Get("X-Mug-Event"), header.Get("X-Mug-Delivery"))
+	}
+
+	testSender(srv).Send(srv.URL, "", RunFinished, []byte("{}"), nil)
+	if _, ok := header["X-Mug-Signature-256"]; ok {
+		t.Error("signed without a secret")
+	}
+}
+
+func TestRender(t *testing.T) {
+	e := Sample(DiffUpdated)
+
+	body, err := Render("", e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Event
+	if err = json.Unmarshal(body, &decoded); err != nil || decoded.Type != DiffUpdated || decoded.Url.Url != e.Url.Url {
+		t.Errorf("default body %s: %v", body, err)
+	}
+
+	body, err = Render(`{"text": {{json (printf "%s %s" .Type .Url.Url)}}, "status": {{json (status .Url.Status)}}}`, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"text": "diffUpdated https://www.example.com/", "status": "fail"}`; string(body) != want {
+		t.Errorf("got %s, want %s", body, want)
+	}
+
+	for _, tmpl := range []string{"{{.Type", "text: {{.Type}}", "{{.Missing}}"} {
+		if _, err = Render(tmpl, e); err == nil {
+			t.Errorf("%q: no error", tmpl)
+		}
+	}
+
+	e.Url.Status = store.WARNING
+	if body, _ = Render(`{{json (status .Url.Status)}}`, e); !strings.Contains(string(body), "warning") {
+		t.Errorf("status of a warning: %s", body)
+	}
+}